@@ -0,0 +1,129 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Reuse what earlier refreshes read about processes that haven't changed.
+// Captures turn this off so that every frame holds every file.
+var incremental = true
+
+// How long a process's smaps totals are trusted before they're re-read
+// anyway. Other processes mapping or unmapping shared pages change PSS
+// without any sign in the process's own stat.
+var fullRefresh = 30 * time.Second
+
+// procKey identifies a process across refreshes. PIDs get reused, but not
+// with the same start time.
+type procKey struct {
+	PID   string
+	Start uint64
+}
+
+// procSignals are cheap figures from stat and statm. A process whose
+// memory changed has almost always faulted, run or had its resident or
+// shared page count change.
+type procSignals struct {
+	MinFlt, MajFlt, CPU uint64
+	Resident, Shared    int
+}
+
+// cachedProc is what earlier refreshes learnt about a process
+type cachedProc struct {
+	// Static attributes, read once per process. The user isn't among
+	// them, since processes like daemons dropping root change their UIDs.
+	Name, Exe string
+	// As of the last full read, kept to skip processes without one in
+	// between. Processes can rewrite their argv, so it's not static.
+	Cmdline string
+	// The process as of its last full read, nil before the first
+	proc    *Process
+	signals procSignals
+	read    time.Time
+	// Last refresh the process was seen in
+	gen int
+}
+
+// Cached processes, pruned of those that have gone after each refresh
+var (
+	procCache   = make(map[procKey]*cachedProc)
+	procCacheMu sync.Mutex
+	cacheGen    int
+)
+
+// cachedProcess returns the cache entry for a process and whether it was
+// already cached. A process whose name changed has exec'd, so it starts
+// over with a fresh entry.
+func cachedProcess(key procKey, name string) (*cachedProc, bool) {
+	if !incremental {
+		return &cachedProc{Name: name}, false
+	}
+	procCacheMu.Lock()
+	defer procCacheMu.Unlock()
+	c, ok := procCache[key]
+	if ok && c.Name == name {
+		c.gen = cacheGen
+		return c, true
+	}
+	c = &cachedProc{Name: name, gen: cacheGen}
+	procCache[key] = c
+	return c, false
+}
+
+// startRefresh marks the start of a refresh of every process
+func startRefresh() {
+	procCacheMu.Lock()
+	cacheGen++
+	procCacheMu.Unlock()
+}
+
+// pruneCache forgets the processes the last refresh didn't see
+func pruneCache() {
+	procCacheMu.Lock()
+	defer procCacheMu.Unlock()
+	for key, c := range procCache {
+		if c.gen != cacheGen {
+			delete(procCache, key)
+		}
+	}
+}
+
+// readSignals gathers the signals of the process at path. Zombies and
+// kernel threads have no statm figures and leave them zero.
+func readSignals(path string, stat *procStat) procSignals {
+	s := procSignals{MinFlt: stat.MinFlt, MajFlt: stat.MajFlt, CPU: stat.UTime + stat.STime}
+	statm, _ := readStatm(path)
+	s.Resident, s.Shared = statm.Resident, statm.Shared
+	return s
+}
+
+// fresh reports whether the last full read still stands: none of the
+// signals moved and no forced re-read is due
+func (c *cachedProc) fresh(s procSignals) bool {
+	return c.proc != nil && c.signals == s && time.Since(c.read) < fullRefresh
+}
+
+// remember keeps a copy of a fully read process and the signals it was
+// read with
+func (c *cachedProc) remember(p *Process, s procSignals) {
+	saved := *p
+	c.proc = &saved
+	c.signals = s
+	c.read = time.Now()
+	c.Exe = p.Exe
+}
+
+// reuse returns a copy of the last full read brought up to date with stat
+func (c *cachedProc) reuse(stat *procStat) *Process {
+	p := *c.proc
+	p.State = stat.State
+	p.PPID = stat.PPID
+	return &p
+}
+
+// cacheKey is the key of the process at path with the given stat
+func cacheKey(path string, stat *procStat) procKey {
+	return procKey{PID: filepath.Base(path), Start: stat.Start}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFresh(t *testing.T) {
+	defer func(saved time.Duration) { fullRefresh = saved }(fullRefresh)
+	fullRefresh = 30 * time.Second
+	signals := procSignals{MinFlt: 81, CPU: 12, Resident: 313, Shared: 281}
+
+	if (&cachedProc{}).fresh(signals) {
+		t.Error("a process never read in full is fresh")
+	}
+	c := &cachedProc{proc: &Process{}, signals: signals, read: time.Now()}
+	if !c.fresh(signals) {
+		t.Error("a process whose signals haven't moved isn't fresh")
+	}
+	moved := map[string]procSignals{
+		"faulted": {MinFlt: 82, CPU: 12, Resident: 313, Shared: 281},
+		"ran":     {MinFlt: 81, CPU: 13, Resident: 313, Shared: 281},
+		"grew":    {MinFlt: 81, CPU: 12, Resident: 314, Shared: 281},
+		"shared":  {MinFlt: 81, CPU: 12, Resident: 313, Shared: 280},
+	}
+	for name, s := range moved {
+		if c.fresh(s) {
+			t.Errorf("a process that %s is fresh", name)
+		}
+	}
+	c.read = time.Now().Add(-fullRefresh)
+	if c.fresh(signals) {
+		t.Errorf("a process read %v ago is fresh", fullRefresh)
+	}
+}
+
+func TestCachedProcess(t *testing.T) {
+	defer func(saved bool) {
+		incremental = saved
+		procCache = make(map[procKey]*cachedProc)
+	}(incremental)
+	incremental = true
+	procCache = make(map[procKey]*cachedProc)
+	key := procKey{PID: "42", Start: 253105}
+
+	startRefresh()
+	c, cached := cachedProcess(key, "sh")
+	if cached {
+		t.Fatal("a new process is cached")
+	}
+	c.Exe = "/bin/sh"
+	startRefresh()
+	if c2, cached := cachedProcess(key, "sh"); !cached || c2 != c {
+		t.Errorf("the same process isn't cached: %v", cached)
+	}
+
+	// Exec keeps the PID and start time but changes the name, and
+	// nothing of the old program carries over
+	startRefresh()
+	c3, cached := cachedProcess(key, "nginx")
+	if cached || c3 == c || c3.Exe != "" {
+		t.Errorf("an exec'd process is cached as %+v", c3)
+	}
+	if procCache[key] != c3 {
+		t.Error("an exec'd process doesn't replace its old entry")
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	defer func(saved bool) {
+		incremental = saved
+		procCache = make(map[procKey]*cachedProc)
+	}(incremental)
+	incremental = true
+	procCache = make(map[procKey]*cachedProc)
+	gone, stays := procKey{PID: "41", Start: 100}, procKey{PID: "42", Start: 200}
+
+	startRefresh()
+	cachedProcess(gone, "sleep")
+	cachedProcess(stays, "sh")
+	pruneCache()
+	if len(procCache) != 2 {
+		t.Fatalf("pruned %d processes seen by the refresh", 2-len(procCache))
+	}
+	startRefresh()
+	cachedProcess(stays, "sh")
+	pruneCache()
+	if _, ok := procCache[gone]; ok {
+		t.Error("a process the last refresh didn't see is still cached")
+	}
+	if _, ok := procCache[stays]; !ok {
+		t.Error("a process the last refresh saw was pruned")
+	}
+}
+
+func TestProcessItCmdline(t *testing.T) {
+	root := t.TempDir()
+	defer func(saved string, incr bool, full time.Duration) {
+		procRoot, incremental, fullRefresh = saved, incr, full
+		procCache = make(map[procKey]*cachedProc)
+	}(procRoot, incremental, fullRefresh)
+	procRoot, incremental, fullRefresh = root, true, 30*time.Second
+	procCache = make(map[procKey]*cachedProc)
+	dir := filepath.Join(root, "42")
+	mkdirs(t, dir)
+	writeFile(t, dir+"/stat", []byte("42 (postgres) S 1 42 42 0 -1 4194304 81 0 0 0 0 0 0 0 20 0 1 0 253105 2703360 313 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n"))
+	writeFile(t, dir+"/status", []byte("Name:\tpostgres\nUid:\t0\t0\t0\t0\nVmRSS:\t1252 kB\n"))
+	writeFile(t, dir+"/statm", []byte("676 313 281 4 0 81 0\n"))
+	writeFile(t, dir+"/cmdline", []byte("postgres\x00-D\x00/var/lib/postgres\x00"))
+
+	startRefresh()
+	if _, ok := processIt(dir); !ok {
+		t.Fatal("processIt failed")
+	}
+
+	// A process that rewrote its argv shows the new one once it's read
+	// in full again, here because its statm moved
+	writeFile(t, dir+"/cmdline", []byte("postgres: checkpointer "))
+	writeFile(t, dir+"/statm", []byte("676 314 281 4 0 81 0\n"))
+	startRefresh()
+	p, ok := processIt(dir)
+	if !ok {
+		t.Fatal("processIt failed")
+	}
+	if p.Command != "postgres: checkpointer " {
+		t.Errorf("command after a full read = %q, want the rewritten argv", p.Command)
+	}
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Version of the capture archive layout, bumped whenever it changes
+const captureVersion = 1
+
+// captureMeta is the first entry of a capture archive
+type captureMeta struct {
+	Version    int
+	Hostname   string
+	ProcRoot   string
+	CgroupRoot string
+	Started    time.Time
+}
+
+// A capture archive is a gzipped tar holding meta.json and then one
+// directory per tick:
+//
+//	frames/000001/time             when the tick was taken, RFC 3339
+//	frames/000001/users            "uid name" lines of the usernames seen
+//	frames/000001/file/<path>      contents of each file read
+//	frames/000001/link/<path>      each symlink read, e.g. /proc/<pid>/exe
+//	frames/000001/stat/<path>      owner and mode of each path stat'd
+//
+// where <path> is the path on the captured host, like proc/1234/stat.
+
+// recordFS passes reads through to another FS and keeps what they returned
+type recordFS struct {
+	FS
+	mu    sync.Mutex
+	files map[string][]byte
+	links map[string]string
+	stats map[string]os.FileInfo
+}
+
+func newRecordFS(inner FS) *recordFS {
+	return &recordFS{
+		FS:    inner,
+		files: make(map[string][]byte),
+		links: make(map[string]string),
+		stats: make(map[string]os.FileInfo),
+	}
+}
+
+func (r *recordFS) Open(name string) (io.ReadCloser, error) {
+	file, err := r.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	contents, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	r.files[name] = contents
+	r.mu.Unlock()
+	return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
+
+func (r *recordFS) Stat(name string) (os.FileInfo, error) {
+	fi, err := r.FS.Stat(name)
+	if err == nil {
+		r.mu.Lock()
+		r.stats[name] = fi
+		r.mu.Unlock()
+	}
+	return fi, err
+}
+
+func (r *recordFS) Readlink(name string) (string, error) {
+	link, err := r.FS.Readlink(name)
+	if err == nil {
+		r.mu.Lock()
+		r.links[name] = link
+		r.mu.Unlock()
+	}
+	return link, err
+}
+
+func (r *recordFS) ReadRegular(name string, max int64) ([]byte, error) {
+	contents, err := r.FS.ReadRegular(name, max)
+	if err == nil {
+		r.mu.Lock()
+		r.files[name] = contents
+		r.mu.Unlock()
+	}
+	return contents, err
+}
+
+// runCapture records what uptop reads every second into a capture archive
+// at dest, until ticks have been taken or it's interrupted. Zero ticks means
+// no limit.
+func runCapture(dest string, ticks int) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	// Replay finds files under "/", so record them by absolute path
+	if procRoot, err = filepath.Abs(procRoot); err != nil {
+		return err
+	}
+	if cgroupRoot, err = filepath.Abs(cgroupRoot); err != nil {
+		return err
+	}
+
+	hostname, _ := os.Hostname()
+	meta, err := json.Marshal(captureMeta{
+		Version:    captureVersion,
+		Hostname:   hostname,
+		ProcRoot:   procRoot,
+		CgroupRoot: cgroupRoot,
+		Started:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(tw, "meta.json", meta); err != nil {
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	// Keep everything so replay can toggle it on, and read every file of
+	// every frame
+	showAll = true
+	incremental = false
+	live := fsys
+	defer func() { fsys = live }()
+frames:
+	for n := 1; ; n++ {
+		rec := newRecordFS(live)
+		fsys = rec
+		procs := collectFrame()
+		if err := writeFrame(tw, n, rec); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "frame %d: %d processes, %d files\n", n, len(procs), len(rec.files))
+		if n == ticks {
+			break
+		}
+		select {
+		case <-ticker.C:
+		case <-stop:
+			break frames
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Whether captures record the full smaps of every process, which the maps
+// and libs views and thread stacks need. Parsing them every frame is slow
+// and makes archives many times larger, so it's left to -capture-maps.
+var captureMaps = false
+
+// collectFrame reads everything the views of the TUI show
+func collectFrame() []*Process {
+	procs := GetProcesses(procRoot)
+	for _, p := range procs {
+		readDetailFiles(p.Basepath)
+		// Name all three UIDs, for -user and the detail view in replay
+		lookupUsername(p.UIDs.Real)
+		lookupUsername(p.UIDs.Effective)
+		lookupUsername(p.UIDs.Saved)
+		if captureMaps {
+			GetThreads(p.Basepath)
+		} else {
+			readTasks(p.Basepath)
+		}
+	}
+	if captureMaps {
+		GetLibraries(procs)
+	}
+	addCgroupMemory(GroupProcesses(procs, func(p *Process) string { return p.Cgroup }))
+	readMeminfo(procRoot)
+	readSocketMemory(procRoot)
+	// Boot time, for when processes started
+	readFile(filepath.Join(procRoot, "stat"))
+	return procs
+}
+
+// readDetailFiles reads what the detail view shows of the process at path
+// that GetProcesses hasn't already read
+func readDetailFiles(path string) {
+	for _, name := range detailFiles {
+		readFile(filepath.Join(path, name))
+	}
+	fsys.Readlink(filepath.Join(path, "cwd"))
+}
+
+// readTasks reads the stat of each thread of the process at path, which is
+// what the threads view lists when there are no mappings to find stacks in
+func readTasks(path string) {
+	dirs, err := fsys.ReadDir(filepath.Join(path, "task"))
+	if err != nil {
+		return
+	}
+	for _, f := range dirs {
+		readStat(filepath.Join(path, "task", f.Name()))
+	}
+}
+
+// writeFrame writes the files, links and stats one tick read
+func writeFrame(tw *tar.Writer, n int, rec *recordFS) error {
+	dir := fmt.Sprintf("frames/%06d", n)
+	if err := writeEntry(tw, path.Join(dir, "time"), []byte(time.Now().Format(time.RFC3339Nano))); err != nil {
+		return err
+	}
+	var users bytes.Buffer
+	ucacheMu.Lock()
+	for uid, name := range ucache {
+		fmt.Fprintf(&users, "%d %s\n", uid, name)
+	}
+	ucacheMu.Unlock()
+	if err := writeEntry(tw, path.Join(dir, "users"), users.Bytes()); err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(rec.files) {
+		if err := writeEntry(tw, path.Join(dir, "file", name), rec.files[name]); err != nil {
+			return err
+		}
+	}
+	for name, link := range rec.links {
+		hdr := &tar.Header{Name: path.Join(dir, "link", name), Typeflag: tar.TypeSymlink, Linkname: link}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+	}
+	for name, fi := range rec.stats {
+		hdr := &tar.Header{Name: path.Join(dir, "stat", name), Typeflag: tar.TypeReg,
+			Mode: int64(fi.Mode().Perm()), ModTime: fi.ModTime()}
+		if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+			hdr.Uid, hdr.Gid = int(st.Uid), int(st.Gid)
+		}
+		if fi.IsDir() {
+			hdr.Typeflag = tar.TypeDir
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntry writes a regular file to the archive
+func writeEntry(tw *tar.Writer, name string, contents []byte) error {
+	hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0444, Size: int64(len(contents))}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(contents)
+	return err
+}
+
+// sortedKeys returns the keys of m in order, so archives are laid out by path
+func sortedKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// parseUsers reads the "uid name" lines of a frame's users entry
+func parseUsers(contents []byte) map[uint32]string {
+	users := make(map[uint32]string)
+	for _, line := range bytes.Split(contents, []byte("\n")) {
+		fields := bytes.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		uid, err := strconv.ParseUint(string(fields[0]), 10, 32)
+		if err != nil {
+			continue
+		}
+		users[uint32(uid)] = string(fields[1])
+	}
+	return users
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCaptureReplay(t *testing.T) {
+	root := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	smaps, err := ioutil.ReadFile("testdata/smaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meminfo, err := ioutil.ReadFile("testdata/proc/meminfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(proc, cgroup string, fs FS, all, incr bool) {
+		os.Chdir(wd)
+		procRoot, cgroupRoot, fsys, replay = proc, cgroup, fs, nil
+		showAll, incremental = all, incr
+		ucache = map[uint32]string{}
+		hostPasswdOnce = sync.Once{}
+	}(procRoot, cgroupRoot, fsys, showAll, incremental)
+	ucache = map[uint32]string{}
+	hostPasswdOnce = sync.Once{}
+
+	// A process whose real, effective and saved UIDs all differ, under
+	// roots given relative to the working directory
+	proc := filepath.Join(root, "proc")
+	mkdirs(t, proc+"/42", proc+"/1/root/etc", root+"/sys/fs/cgroup")
+	writeFile(t, proc+"/meminfo", meminfo)
+	writeFile(t, proc+"/stat", []byte("btime 1709290000\n"))
+	writeFile(t, proc+"/1/root/etc/passwd", []byte("root:x:0:0:root:/root:/bin/sh\nalice:x:1000:1000::/home/alice:/bin/sh\nbob:x:1001:1001::/home/bob:/bin/sh\n"))
+	writeFile(t, proc+"/42/stat", []byte("42 (sleep) S 1 42 42 0 -1 4194304 81 0 0 0 0 0 0 0 20 0 1 0 253105 2703360 313 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n"))
+	writeFile(t, proc+"/42/cmdline", []byte("sleep\x00infinity\x00"))
+	writeFile(t, proc+"/42/status", []byte("Name:\tsleep\nUid:\t1000\t0\t1001\t0\n"))
+	writeFile(t, proc+"/42/statm", []byte("676 313 281 4 0 81 0\n"))
+	writeFile(t, proc+"/42/smaps", smaps)
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	procRoot, cgroupRoot = "proc", "sys/fs/cgroup"
+	dest := filepath.Join(root, "capture.tgz")
+	if err := runCapture(dest, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay from elsewhere, with none of the names cached
+	if err := os.Chdir(wd); err != nil {
+		t.Fatal(err)
+	}
+	ucache = map[uint32]string{}
+	a, err := loadArchive(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replay, fsys = a, a
+	procRoot, cgroupRoot = a.Meta.ProcRoot, a.Meta.CgroupRoot
+	if _, err := readMeminfo(procRoot); err != nil {
+		t.Errorf("readMeminfo: %v", err)
+	}
+	procs := GetProcesses(procRoot)
+	if len(procs) != 1 {
+		t.Fatalf("replay found %d processes, want 1", len(procs))
+	}
+	if p := procs[0]; p.PID != 42 || p.Name != "sleep" || p.User != "root" {
+		t.Errorf("replay found PID %d %q run by %q, want 42 sleep run by root", p.PID, p.Name, p.User)
+	}
+	for uid, want := range map[uint32]string{0: "root", 1000: "alice", 1001: "bob"} {
+		if got := lookupUsername(uid); got != want {
+			t.Errorf("lookupUsername(%d) = %q in replay, want %q", uid, got, want)
+		}
+	}
+}
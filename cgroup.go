@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bufio"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Mount point of the cgroup v2 hierarchy
+var cgroupRoot = "/sys/fs/cgroup"
+
+// CgroupMemory is a cgroup's own memory accounting, in kB
+type CgroupMemory struct {
+	Current int
+	// Max is -1 when memory.max is "max"
+	Max int
+	// Breakdown of Current from memory.stat
+	Anon, File, Kernel, Sock, Shmem int
+}
+
+// readCgroups returns the cgroup v2 path of the process at path, from the
+// "0::/path" line of its cgroup file, along with the path of every hierarchy
+func readCgroups(path string) (string, []string) {
+	cgroup, err := readFile(filepath.Join(path, "cgroup"))
+	if err != nil {
+		return "", nil
+	}
+	v2 := ""
+	var paths []string
+	for _, line := range strings.Split(strings.TrimSpace(string(cgroup)), "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[0] == "0" && fields[1] == "" {
+			v2 = fields[2]
+		}
+		paths = append(paths, fields[2])
+	}
+	return v2, paths
+}
+
+// unifiedRoot returns the directory the cgroup v2 hierarchy is mounted at.
+// Hosts in hybrid mode mount it under unified/ next to the v1 controllers.
+func unifiedRoot() string {
+	if _, err := fsys.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		return cgroupRoot
+	}
+	unified := filepath.Join(cgroupRoot, "unified")
+	if _, err := fsys.Stat(filepath.Join(unified, "cgroup.controllers")); err == nil {
+		return unified
+	}
+	return cgroupRoot
+}
+
+// readCgroupMemory reads memory.current, memory.max and memory.stat of the
+// cgroup at the given v2 path. It fails for the root cgroup and wherever the
+// memory controller isn't enabled.
+func readCgroupMemory(cgpath string) (*CgroupMemory, error) {
+	dir := filepath.Join(unifiedRoot(), cgpath)
+	current, err := readCgroupValue(filepath.Join(dir, "memory.current"))
+	if err != nil {
+		return nil, err
+	}
+	max, err := readCgroupValue(filepath.Join(dir, "memory.max"))
+	if err != nil {
+		return nil, err
+	}
+	m := &CgroupMemory{Current: current, Max: max}
+
+	file, err := fsys.Open(filepath.Join(dir, "memory.stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if v, err := strconv.Atoi(fields[1]); err == nil {
+			stat[fields[0]] = v / 1024
+		}
+	}
+	m.Anon = stat["anon"]
+	m.File = stat["file"]
+	m.Sock = stat["sock"]
+	m.Shmem = stat["shmem"]
+	m.Kernel = stat["kernel"]
+	// Kernels before 5.18 only break kernel memory down
+	if _, ok := stat["kernel"]; !ok {
+		m.Kernel = stat["kernel_stack"] + stat["pagetables"] + stat["percpu"] + stat["slab"]
+	}
+	return m, scanner.Err()
+}
+
+// readCgroupValue reads a cgroup file holding a byte count or "max",
+// returning kB or -1 for "max"
+func readCgroupValue(path string) (int, error) {
+	contents, err := readFile(path)
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(contents))
+	if value == "max" {
+		return -1, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	return v / 1024, nil
+}
+
+// addCgroupMemory reads the memory accounting of each cgroup group
+func addCgroupMemory(groups []*Group) {
+	for _, g := range groups {
+		g.Cgroup, _ = readCgroupMemory(g.Key)
+	}
+}
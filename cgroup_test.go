@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReadCgroups(t *testing.T) {
+	v2, paths := readCgroups("testdata/proc/27933")
+	if v2 != "/system.slice/cron.service" {
+		t.Errorf("v2 path = %q, want /system.slice/cron.service", v2)
+	}
+	if len(paths) != 13 || paths[1] != "/system.slice/cron.service" || paths[0] != "/" {
+		t.Errorf("paths = %q, want every hierarchy's in order", paths)
+	}
+	if v2, paths := readCgroups("testdata/proc/27999"); v2 != "" || paths != nil {
+		t.Errorf("missing cgroup file gave %q, %q", v2, paths)
+	}
+}
+
+func TestReadCgroupMemory(t *testing.T) {
+	defer func(saved string) { cgroupRoot = saved }(cgroupRoot)
+	tests := []struct {
+		root, path string
+		want       *CgroupMemory
+	}{
+		{"testdata/sys/fs/cgroup", "/system.slice/nginx.service",
+			&CgroupMemory{Current: 51576, Max: 262144, Anon: 12268, File: 37008, Kernel: 2048, Sock: 4, Shmem: 1024}},
+		// No kernel total before 5.18, and no limit
+		{"testdata/sys/fs/cgroup", "/system.slice/cron.service",
+			&CgroupMemory{Current: 3136, Max: -1, Anon: 1056, File: 1620, Kernel: 16 + 68 + 1 + 370}},
+		// A hybrid host, with the v2 hierarchy under unified/
+		{"testdata/hybrid/sys/fs/cgroup", "/system.slice/cron.service",
+			&CgroupMemory{Current: 3136, Max: -1, Anon: 1056, File: 1620, Kernel: 16 + 68 + 1 + 370}},
+		// The memory controller isn't enabled
+		{"testdata/sys/fs/cgroup", "/user.slice", nil},
+		{"testdata/sys/fs/cgroup", "/", nil},
+	}
+	for _, tt := range tests {
+		cgroupRoot = tt.root
+		got, err := readCgroupMemory(tt.path)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("readCgroupMemory(%s under %s) = %+v, want an error", tt.path, tt.root, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("readCgroupMemory(%s under %s): %v", tt.path, tt.root, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("readCgroupMemory(%s under %s) = %+v, want %+v", tt.path, tt.root, got, tt.want)
+		}
+	}
+}
+
+func TestUnifiedRoot(t *testing.T) {
+	defer func(saved string) { cgroupRoot = saved }(cgroupRoot)
+	for root, want := range map[string]string{
+		"testdata/sys/fs/cgroup":        "testdata/sys/fs/cgroup",
+		"testdata/hybrid/sys/fs/cgroup": "testdata/hybrid/sys/fs/cgroup/unified",
+		// Nothing mounted at all
+		"testdata/nowhere": "testdata/nowhere",
+	} {
+		cgroupRoot = root
+		if got := unifiedRoot(); got != want {
+			t.Errorf("unifiedRoot with %s = %s, want %s", root, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// column is an optional column of the process table
+type column struct {
+	// Key names the column for -columns and -sort
+	Key   string
+	Title string
+	Value func(p *Process) int
+}
+
+// Optional columns, in the order they're shown
+var counterColumns = []column{
+	{"sharedclean", "ShClean", func(p *Process) int { return p.SharedClean }},
+	{"shareddirty", "ShDirty", func(p *Process) int { return p.SharedDirty }},
+	{"privateclean", "PrClean", func(p *Process) int { return p.PrivateClean }},
+	{"privatedirty", "PrDirty", func(p *Process) int { return p.PrivateDirty }},
+	{"referenced", "Refd", func(p *Process) int { return p.Referenced }},
+	{"anonymous", "Anon", func(p *Process) int { return p.Anonymous }},
+	{"lazyfree", "LazyFree", func(p *Process) int { return p.LazyFree }},
+	{"anonhugepages", "AnonHuge", func(p *Process) int { return p.AnonHugePages }},
+	{"shmempmdmapped", "ShmemPmd", func(p *Process) int { return p.ShmemPmdMapped }},
+	{"filepmdmapped", "FilePmd", func(p *Process) int { return p.FilePmdMapped }},
+	{"sharedhugetlb", "ShHugetlb", func(p *Process) int { return p.SharedHugetlb }},
+	{"privatehugetlb", "PrHugetlb", func(p *Process) int { return p.PrivateHugetlb }},
+	{"rawswap", "RawSwap", func(p *Process) int { return p.RawSwap }},
+	{"locked", "Locked", func(p *Process) int { return p.Locked }},
+	{"pssanon", "PssAnon", func(p *Process) int { return p.PssAnon }},
+	{"pssfile", "PssFile", func(p *Process) int { return p.PssFile }},
+	{"pssshmem", "PssShmem", func(p *Process) int { return p.PssShmem }},
+	{"shared", "Shared", func(p *Process) int { return p.Shared }},
+	{"text", "Text", func(p *Process) int { return p.Text }},
+	{"dpss", "dPSS", func(p *Process) int { return p.DeltaPSS }},
+	{"duss", "dUSS", func(p *Process) int { return p.DeltaUSS }},
+	{"dswap", "dSwap", func(p *Process) int { return p.DeltaSwap }},
+	{"gpss", "GrowPSS", func(p *Process) int { return p.GrowthPSS }},
+	{"guss", "GrowUSS", func(p *Process) int { return p.GrowthUSS }},
+	{"gswap", "GrowSwap", func(p *Process) int { return p.GrowthSwap }},
+}
+
+// Columns that partially read processes still have figures for
+var partialColumns = map[string]bool{"anonymous": true, "rawswap": true, "locked": true, "shared": true, "text": true}
+
+// Optional columns shown unless -columns says otherwise. They're from statm,
+// so processes whose smaps can't be read still show more than RSS.
+const defaultColumns = "shared,text"
+
+// Optional columns chosen with -columns
+var extraColumns []column
+
+// Sort keys the TUI cycles through, in order
+var baseSortKeys = []string{"rss", "pss", "uss", "swap", "name"}
+
+// findColumn returns the optional column with the given key
+func findColumn(key string) (column, bool) {
+	for _, c := range counterColumns {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return column{}, false
+}
+
+// parseColumns turns a comma separated list of column keys, "all" or
+// "none", into columns
+func parseColumns(list string) ([]column, error) {
+	if list == "" || list == "none" {
+		return nil, nil
+	}
+	if list == "all" {
+		return counterColumns, nil
+	}
+	var cols []column
+	for _, key := range strings.Split(list, ",") {
+		c, ok := findColumn(strings.TrimSpace(key))
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", key)
+		}
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
+
+// columnKeys lists the keys of every optional column
+func columnKeys() []string {
+	var keys []string
+	for _, c := range counterColumns {
+		keys = append(keys, c.Key)
+	}
+	return keys
+}
+
+// nextSortKey returns the sort key after the current one, cycling through
+// the base keys and then the optional columns being shown
+func nextSortKey(current string) string {
+	keys := append([]string{}, baseSortKeys...)
+	for _, c := range extraColumns {
+		keys = append(keys, c.Key)
+	}
+	for i, k := range keys {
+		if k == current {
+			return keys[(i+1)%len(keys)]
+		}
+	}
+	return keys[0]
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{defaultColumns, []string{"Shared", "Text"}},
+		{"none", nil},
+		{"", nil},
+		{"privatedirty, pssanon", []string{"PrDirty", "PssAnon"}},
+	}
+	for _, tt := range tests {
+		cols, err := parseColumns(tt.list)
+		if err != nil {
+			t.Errorf("parseColumns(%q): %v", tt.list, err)
+			continue
+		}
+		var titles []string
+		for _, c := range cols {
+			titles = append(titles, c.Title)
+		}
+		if !reflect.DeepEqual(titles, tt.want) {
+			t.Errorf("parseColumns(%q) = %v, want %v", tt.list, titles, tt.want)
+		}
+	}
+	if _, err := parseColumns("shared,bogus"); err == nil {
+		t.Error("parseColumns accepted an unknown column")
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Container identifies the container a process runs in. The zero value
+// means the process runs on the host.
+type Container struct {
+	// Runtime is docker, containerd, crio or podman
+	Runtime string
+	ID      string
+	// Pod is the Kubernetes pod UID, if the container belongs to one
+	Pod string
+}
+
+// Cgroup path segments naming a container, as systemd scopes like
+// docker-<id>.scope or as bare IDs under a cgroupfs parent
+var (
+	scopergx = regexp.MustCompile(`^(docker|cri-containerd|crio|libpod|nerdctl)-([0-9a-f]{64})\.scope$`)
+	idrgx    = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	podrgx   = regexp.MustCompile(`pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})`)
+)
+
+// Runtimes by the prefix of their systemd scopes
+var scopeRuntimes = map[string]string{
+	"docker":         "docker",
+	"cri-containerd": "containerd",
+	"nerdctl":        "containerd",
+	"crio":           "crio",
+	"libpod":         "podman",
+}
+
+// parseContainer finds the container in a process's cgroup paths
+func parseContainer(paths []string) Container {
+	for _, path := range paths {
+		if c, ok := parseContainerPath(path); ok {
+			return c
+		}
+	}
+	return Container{}
+}
+
+// parseContainerPath looks for a container ID in one cgroup path, e.g.
+//
+//	/system.slice/docker-<id>.scope
+//	/docker/<id>
+//	/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod<uid>.slice/crio-<id>.scope
+//	/kubepods/burstable/pod<uid>/<id>
+//	/machine.slice/libpod-<id>.scope
+func parseContainerPath(path string) (Container, bool) {
+	var c Container
+	parent := ""
+	for _, seg := range strings.Split(path, "/") {
+		if m := podrgx.FindStringSubmatch(seg); m != nil {
+			c.Pod = strings.Replace(m[1], "_", "-", -1)
+		}
+		if m := scopergx.FindStringSubmatch(seg); m != nil {
+			c.Runtime, c.ID = scopeRuntimes[m[1]], m[2]
+			return c, true
+		}
+		if idrgx.MatchString(seg) {
+			c.ID = seg
+			switch {
+			case parent == "docker":
+				c.Runtime = "docker"
+			case parent == "libpod_parent":
+				c.Runtime = "podman"
+			default:
+				// Kubernetes on cgroupfs and plain containerd namespaces
+				c.Runtime = "containerd"
+			}
+			return c, true
+		}
+		parent = seg
+	}
+	return Container{}, false
+}
+
+// String is the short form of the container shown in the table, like
+// docker:4f3c2a1b0e9d, prefixed with pod:<uid>/ for Kubernetes containers
+func (c Container) String() string {
+	if c.ID == "" {
+		return ""
+	}
+	s := c.Runtime + ":" + c.ID[:12]
+	if c.Pod != "" {
+		s = "pod:" + c.Pod[:8] + "/" + s
+	}
+	return s
+}
+
+// containerKey groups by container, with host processes together
+func containerKey(p *Process) string {
+	if p.Container.ID == "" {
+		return "host"
+	}
+	return p.Container.String()
+}
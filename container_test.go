@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestParseContainerPath(t *testing.T) {
+	const (
+		id  = "4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b"
+		pod = "1234abcd-5678-90ef-1234-567890abcdef"
+	)
+	tests := []struct {
+		path string
+		want Container
+		ok   bool
+	}{
+		// Docker with the systemd and cgroupfs drivers
+		{"/system.slice/docker-" + id + ".scope", Container{Runtime: "docker", ID: id}, true},
+		{"/docker/" + id, Container{Runtime: "docker", ID: id}, true},
+		// Podman, rootful and rootless
+		{"/machine.slice/libpod-" + id + ".scope", Container{Runtime: "podman", ID: id}, true},
+		{"/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + id + ".scope/container",
+			Container{Runtime: "podman", ID: id}, true},
+		{"/libpod_parent/" + id, Container{Runtime: "podman", ID: id}, true},
+		// Kubernetes with the systemd driver, where the pod UID uses underscores
+		{"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod1234abcd_5678_90ef_1234_567890abcdef.slice/crio-" + id + ".scope",
+			Container{Runtime: "crio", ID: id, Pod: pod}, true},
+		{"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234abcd_5678_90ef_1234_567890abcdef.slice/cri-containerd-" + id + ".scope",
+			Container{Runtime: "containerd", ID: id, Pod: pod}, true},
+		// Kubernetes with the cgroupfs driver
+		{"/kubepods/burstable/pod" + pod + "/" + id, Container{Runtime: "containerd", ID: id, Pod: pod}, true},
+		{"/kubepods/pod" + pod + "/" + id, Container{Runtime: "containerd", ID: id, Pod: pod}, true},
+		// Host processes
+		{"/", Container{}, false},
+		{"/init.scope", Container{}, false},
+		{"/system.slice/sshd.service", Container{}, false},
+		{"/user.slice/user-1000.slice/session-3.scope", Container{}, false},
+		// A pod's own cgroup isn't a container
+		{"/kubepods/burstable/pod" + pod, Container{}, false},
+		// Too short to be a container ID
+		{"/docker/4f3c2a1b0e9d", Container{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseContainerPath(tt.path)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseContainerPath(%q) = %+v, %v; want %+v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestContainerString(t *testing.T) {
+	const id = "4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b"
+	tests := []struct {
+		c    Container
+		want string
+	}{
+		{Container{}, ""},
+		{Container{Runtime: "docker", ID: id}, "docker:4f3c2a1b0e9d"},
+		{Container{Runtime: "crio", ID: id, Pod: "1234abcd-5678-90ef-1234-567890abcdef"}, "pod:1234abcd/crio:4f3c2a1b0e9d"},
+	}
+	for _, tt := range tests {
+		if got := tt.c.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Clock ticks per second that stat times are counted in. USER_HZ is 100 on
+// every architecture Linux supports.
+const clockTicks = 100
+
+// detailLine is one field of the detail view of a process. Lines without a
+// value head a section.
+type detailLine struct {
+	Label, Value string
+}
+
+// Order smaps totals are listed in, which is the kernel's. Anything else
+// follows alphabetically.
+var smapsOrder = []string{"Rss", "Pss", "Pss_Dirty", "Pss_Anon", "Pss_File", "Pss_Shmem",
+	"Shared_Clean", "Shared_Dirty", "Private_Clean", "Private_Dirty", "Referenced", "Anonymous",
+	"KSM", "LazyFree", "AnonHugePages", "ShmemPmdMapped", "FilePmdMapped", "Shared_Hugetlb",
+	"Private_Hugetlb", "Swap", "SwapPss", "Locked"}
+
+// Fields of status shown in the detail view
+var statusFields = []string{"VmPeak", "VmSize", "VmHWM", "VmRSS", "VmData", "VmStk", "VmExe",
+	"VmLib", "VmPTE", "VmSwap", "Threads"}
+
+// Files of a process the detail view reads besides those every refresh does
+var detailFiles = []string{"oom_score", "oom_score_adj"}
+
+// readDetail gathers everything uptop can learn about the process at path
+func readDetail(path string) ([]detailLine, error) {
+	stat, err := readStat(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Process{Basepath: path, Command: getCmdline(path)}
+	if err := p.PopulateInfo(stat); err != nil {
+		return nil, err
+	}
+	lines := []detailLine{
+		{"Process", ""},
+		{"PID", strconv.Itoa(p.PID)},
+		{"Parent PID", strconv.Itoa(p.PPID)},
+		{"Name", p.Name},
+		{"State", p.State},
+		{"User", fmt.Sprintf("%s (UIDs %d real, %d effective, %d saved)",
+			p.User, p.UIDs.Real, p.UIDs.Effective, p.UIDs.Saved)},
+		{"Started", startedAt(stat.Start)},
+		{"Exe", orUnavailable(p.Exe, nil)},
+	}
+	cwd, err := fsys.Readlink(filepath.Join(path, "cwd"))
+	lines = append(lines, detailLine{"Cwd", orUnavailable(cwd, err)})
+	lines = append(lines, detailLine{"Cgroup", orUnavailable(p.Cgroup, nil)})
+	if p.Container.ID != "" {
+		lines = append(lines, detailLine{"Container", p.Container.Runtime + " " + p.Container.ID})
+	}
+	if p.Container.Pod != "" {
+		lines = append(lines, detailLine{"Pod", p.Container.Pod})
+	}
+	if p.Unit != "" {
+		lines = append(lines, detailLine{"Systemd unit", p.Unit})
+	}
+	if p.Manager != "" {
+		lines = append(lines, detailLine{"Run by", p.Manager})
+	}
+	if p.Session != "" {
+		lines = append(lines, detailLine{"Login session", p.Session})
+	}
+	for _, name := range detailFiles {
+		value, err := readFile(filepath.Join(path, name))
+		lines = append(lines, detailLine{name, orUnavailable(strings.TrimSpace(string(value)), err)})
+	}
+	lines = append(lines, detailLine{"Command", p.Command})
+
+	totals, source, err := readSmapsTotals(path)
+	if err != nil {
+		lines = append(lines, detailLine{"smaps", ""}, detailLine{"Totals", orUnavailable("", err)})
+	} else {
+		lines = append(lines, detailLine{"Totals from " + source + ", kB", ""})
+		for _, key := range smapsKeys(totals) {
+			lines = append(lines, detailLine{key, strconv.Itoa(totals[key])})
+		}
+	}
+
+	status, err := readStatus(path)
+	lines = append(lines, detailLine{"From status", ""})
+	if err != nil {
+		lines = append(lines, detailLine{"Fields", orUnavailable("", err)})
+	}
+	for _, key := range statusFields {
+		if value, ok := status[key]; ok {
+			lines = append(lines, detailLine{key, value})
+		}
+	}
+	return lines, nil
+}
+
+// orUnavailable is value, or why it couldn't be read
+func orUnavailable(value string, err error) string {
+	if err != nil {
+		return fmt.Sprintf("unavailable: %v", err)
+	}
+	if value == "" {
+		return "unavailable"
+	}
+	return value
+}
+
+// startedAt formats the start time from stat, counted in clock ticks from
+// boot, as a time and an age
+func startedAt(ticks uint64) string {
+	info, err := readFile(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return orUnavailable("", err)
+	}
+	for _, line := range strings.Split(string(info), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || fields[0] != "btime" {
+			continue
+		}
+		btime, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return orUnavailable("", err)
+		}
+		started := time.Unix(btime, 0).Add(time.Duration(ticks) * time.Second / clockTicks)
+		age := sampleTime().Sub(started).Round(time.Second)
+		return fmt.Sprintf("%s, %s ago", started.Format("2006-01-02 15:04:05"), age)
+	}
+	return orUnavailable("", nil)
+}
+
+// smapsKeys lists the keys of smaps totals in the kernel's order
+func smapsKeys(totals map[string]int) []string {
+	var keys []string
+	known := make(map[string]bool)
+	for _, key := range smapsOrder {
+		known[key] = true
+		if _, ok := totals[key]; ok {
+			keys = append(keys, key)
+		}
+	}
+	var rest []string
+	for key := range totals {
+		if !known[key] {
+			rest = append(rest, key)
+		}
+	}
+	sort.Strings(rest)
+	return append(keys, rest...)
+}
+
+// Formats the detail view for the termui table, returning the row keys and
+// which rows head sections. Values longer than width, like long command
+// lines, carry on over as many rows as they need.
+func detailFormat(lines []detailLine, width int) ([][]string, []string, []int) {
+	tab := [][]string{{"Field", "Value"}, {"-----", "-----"}}
+	var keys []string
+	var sections []int
+	if width < 1 {
+		width = 1
+	}
+	for _, l := range lines {
+		if l.Value == "" {
+			sections = append(sections, len(keys))
+		}
+		// Arguments can hold newlines and tabs, which the table can't show
+		value := []rune(strings.Map(func(r rune) rune {
+			if unicode.IsControl(r) {
+				return ' '
+			}
+			return r
+		}, l.Value))
+		label := l.Label
+		for {
+			n := len(value)
+			if n > width {
+				n = width
+			}
+			tab = append(tab, []string{label, string(value[:n])})
+			keys = append(keys, fmt.Sprintf("%s:%d", l.Label, len(keys)))
+			value = value[n:]
+			label = ""
+			if len(value) == 0 {
+				break
+			}
+		}
+	}
+	return tab, keys, sections
+}
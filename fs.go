@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// FS is what uptop reads procfs and the cgroup hierarchy through. Names are
+// full paths under procRoot or cgroupRoot.
+type FS interface {
+	Open(name string) (io.ReadCloser, error)
+	ReadDir(name string) ([]os.FileInfo, error)
+	Stat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+	// ReadRegular reads a regular file of at most max bytes without
+	// following a symlink at name or at the directory holding it, or
+	// blocking on a FIFO. It's for files a process controls, like those
+	// under /proc/<pid>/root.
+	ReadRegular(name string, max int64) ([]byte, error)
+}
+
+// osFS reads straight from the local filesystem
+type osFS struct{}
+
+func (osFS) Open(name string) (io.ReadCloser, error)    { return os.Open(name) }
+func (osFS) ReadDir(name string) ([]os.FileInfo, error) { return ioutil.ReadDir(name) }
+func (osFS) Stat(name string) (os.FileInfo, error)      { return os.Stat(name) }
+func (osFS) Readlink(name string) (string, error)       { return os.Readlink(name) }
+
+// ReadRegular opens the file relative to its directory, so neither can be
+// swapped for a symlink between the checks and the read
+func (osFS) ReadRegular(name string, max int64) ([]byte, error) {
+	dirname := filepath.Dir(name)
+	dir, err := syscall.Open(dirname, syscall.O_RDONLY|syscall.O_DIRECTORY|syscall.O_NOFOLLOW|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: dirname, Err: err}
+	}
+	defer syscall.Close(dir)
+	fd, err := syscall.Openat(dir, filepath.Base(name), syscall.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	file := os.NewFile(uintptr(fd), name)
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", name)
+	}
+	return readLimited(name, file, max)
+}
+
+// Filesystem every read goes through
+var fsys FS = osFS{}
+
+// Where procfs is mounted. A container can point this at the host's procfs
+// bind mounted elsewhere, or at a saved copy of a procfs tree.
+var procRoot = "/proc"
+
+// readFile reads a whole file through fsys
+func readFile(name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
+
+// readLimited reads all of r, failing once it holds more than max bytes
+func readLimited(name string, r io.Reader, max int64) ([]byte, error) {
+	contents, err := ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(contents)) > max {
+		return nil, fmt.Errorf("%s is larger than %d bytes", name, max)
+	}
+	return contents, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Group sums the processes that share a grouping key
+type Group struct {
+	Key                 string
+	RSS, PSS, USS, Swap int
+	Procs               []*Process
+	// Number of processes whose smaps couldn't be read, which PSS, USS
+	// and Swap leave out
+	Partial int
+	// Cgroup is the cgroup's own accounting when grouping by cgroup
+	Cgroup *CgroupMemory
+}
+
+// grouping is a way of collapsing the process list
+type grouping struct {
+	Name  string
+	Title string
+	Key   func(p *Process) string
+}
+
+// Grouping modes, in the order the TUI cycles through them
+var groupings = []grouping{
+	{"user", "User", func(p *Process) string { return p.User }},
+	{"program", "Program", func(p *Process) string { return p.Name }},
+	{"exe", "Executable", exeKey},
+	{"cgroup", "Cgroup", func(p *Process) string { return p.Cgroup }},
+	{"container", "Container", containerKey},
+	{"unit", "Unit", unitKey},
+	{"slice", "Slice", sliceKey},
+	{"session", "Session", sessionKey},
+}
+
+// Current grouping mode, empty for the flat process list
+var groupBy = ""
+
+// exeKey groups by executable path, or by the bracketed stat name when the
+// exe link can't be read, as with kernel threads or without permission
+func exeKey(p *Process) string {
+	if p.Exe == "" {
+		return "[" + p.Name + "]"
+	}
+	return p.Exe
+}
+
+// findGrouping returns the grouping mode with the given name
+func findGrouping(name string) (grouping, bool) {
+	for _, g := range groupings {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return grouping{}, false
+}
+
+// groupNames lists the names of every grouping mode
+func groupNames() []string {
+	var names []string
+	for _, g := range groupings {
+		names = append(names, g.Name)
+	}
+	return names
+}
+
+// nextGrouping returns the grouping mode after the current one, cycling
+// back to the flat process list after the last
+func nextGrouping(current string) string {
+	if current == "" {
+		return groupings[0].Name
+	}
+	for i, g := range groupings {
+		if g.Name == current && i+1 < len(groupings) {
+			return groupings[i+1].Name
+		}
+	}
+	return ""
+}
+
+// GroupProcesses collapses the processes by key and sums their memory.
+// The processes within each group keep their order.
+func GroupProcesses(procs []*Process, key func(p *Process) string) []*Group {
+	byKey := make(map[string]*Group)
+	var groups []*Group
+	for _, p := range procs {
+		k := key(p)
+		g, ok := byKey[k]
+		if !ok {
+			g = &Group{Key: k}
+			byKey[k] = g
+			groups = append(groups, g)
+		}
+		g.RSS += p.RSS
+		g.PSS += p.PSS
+		g.USS += p.USS
+		g.Swap += p.Swap
+		g.Procs = append(g.Procs, p)
+		if p.Partial {
+			g.Partial++
+		}
+	}
+	sortGroups(groups, sortKey)
+	return groups
+}
+
+// sortGroups orders groups by key, then by the given sort key. Name sorts
+// ascending, all else sorts descending.
+func sortGroups(groups []*Group, key string) {
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].Key < groups[j].Key })
+	switch key {
+	case "count":
+		sort.SliceStable(groups, func(i, j int) bool { return len(groups[i].Procs) > len(groups[j].Procs) })
+	case "rss":
+		sort.SliceStable(groups, func(i, j int) bool { return groups[i].RSS > groups[j].RSS })
+	case "pss":
+		sort.SliceStable(groups, func(i, j int) bool { return groups[i].PSS > groups[j].PSS })
+	case "uss":
+		sort.SliceStable(groups, func(i, j int) bool { return groups[i].USS > groups[j].USS })
+	case "swap":
+		sort.SliceStable(groups, func(i, j int) bool { return groups[i].Swap > groups[j].Swap })
+	}
+}
+
+// Formats the groups for the termui table. The processes of expanded
+// groups, keyed by group key, are listed under them. Group rows are keyed
+// "g:<key>" and process rows by PID. Sums leaving out processes without
+// smaps are marked with *.
+func groupsFormat(groups []*Group, title string, expanded map[string]bool) ([][]string, []string) {
+	head := []string{"Procs", "SwapPSS", "USS", "PSS", "RSS"}
+	dash := []string{"-----", "-------", "---", "---", "---"}
+	if groupBy == "cgroup" {
+		head = append(head, cgroupTitles...)
+		for _, t := range cgroupTitles {
+			dash = append(dash, strings.Repeat("-", len(t)))
+		}
+	}
+	tab := [][]string{append(head, title), append(dash, strings.Repeat("-", len(title)))}
+	var keys []string
+	for _, g := range groups {
+		marker := "+ "
+		if expanded[g.Key] {
+			marker = "- "
+		}
+		partial := g.Partial > 0
+		row := []string{strconv.Itoa(len(g.Procs)), totalCell(g.Swap, partial),
+			totalCell(g.USS, partial), totalCell(g.PSS, partial), strconv.Itoa(g.RSS)}
+		if groupBy == "cgroup" {
+			row = append(row, cgroupCells(g)...)
+		}
+		tab = append(tab, append(row, marker+g.Key))
+		keys = append(keys, "g:"+g.Key)
+		if !expanded[g.Key] {
+			continue
+		}
+		for _, p := range g.Procs {
+			row := []string{"", partialCell(p, p.Swap), partialCell(p, p.USS),
+				partialCell(p, p.PSS), strconv.Itoa(p.RSS)}
+			if groupBy == "cgroup" {
+				row = append(row, make([]string, len(cgroupTitles))...)
+			}
+			tab = append(tab, append(row, fmt.Sprintf("    %d %s", p.PID, p.Command)))
+			keys = append(keys, strconv.Itoa(p.PID))
+		}
+	}
+	return tab, keys
+}
+
+// Titles of the columns shown for the cgroup's own accounting. Gap is
+// memory.current less the summed PSS of the cgroup's processes: page cache
+// and kernel memory charged to the cgroup. It's overstated, and marked with
+// a *, when some of the processes' smaps couldn't be read.
+var cgroupTitles = []string{"Current", "Max", "Anon", "File", "Kernel", "Sock", "Shmem", "Gap"}
+
+// cgroupCells formats the cgroup accounting of a group, or dashes when it
+// couldn't be read
+func cgroupCells(g *Group) []string {
+	cells := make([]string, len(cgroupTitles))
+	if g.Cgroup == nil {
+		for i := range cells {
+			cells[i] = "-"
+		}
+		return cells
+	}
+	m := g.Cgroup
+	max := "max"
+	if m.Max >= 0 {
+		max = strconv.Itoa(m.Max)
+	}
+	return []string{strconv.Itoa(m.Current), max, strconv.Itoa(m.Anon), strconv.Itoa(m.File),
+		strconv.Itoa(m.Kernel), strconv.Itoa(m.Sock), strconv.Itoa(m.Shmem),
+		totalCell(m.Current-g.PSS, g.Partial > 0)}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupsPartial(t *testing.T) {
+	defer func(by, key string) { groupBy, sortKey = by, key }(groupBy, sortKey)
+	groupBy, sortKey = "cgroup", "pss"
+	procs := []*Process{
+		{PID: 100, Cgroup: "/system.slice/nginx.service", RSS: 50, PSS: 30, USS: 20, Swap: 1},
+		{PID: 101, Cgroup: "/system.slice/nginx.service", RSS: 45, Partial: true},
+		{PID: 200, Cgroup: "/system.slice/sshd.service", RSS: 8, PSS: 6, USS: 4},
+	}
+	groups := GroupProcesses(procs, func(p *Process) string { return p.Cgroup })
+	groups[0].Cgroup = &CgroupMemory{Current: 100, Max: -1}
+	groups[1].Cgroup = &CgroupMemory{Current: 10, Max: -1}
+	tab, _ := groupsFormat(groups, "Cgroup", map[string]bool{"/system.slice/nginx.service": true})
+	want := [][]string{
+		// Procs, SwapPSS, USS, PSS, RSS, Gap
+		{"2", "1*", "20*", "30*", "95", "70*"},
+		{"", "1", "20", "30", "50", ""},
+		{"", "-", "-", "-", "45", ""},
+		{"1", "0", "4", "6", "8", "4"},
+	}
+	var got [][]string
+	for _, row := range tab[2:] {
+		got = append(got, append(row[:5:5], row[len(row)-2]))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupsFormat =\n%v\nwant\n%v", got, want)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strconv"
+	"time"
+)
+
+// Sort keys for the processes growing fastest, in kB per second over the
+// last sample, and those that have grown most since uptop started. Growth
+// is USS plus SwapPSS, so private memory being swapped out doesn't look
+// like it shrank.
+const (
+	sortGrowing = "growing"
+	sortGrowth  = "growth"
+)
+
+// footprint is the part of a process's memory that tells whether it grew
+type footprint struct {
+	PSS, USS, Swap int
+}
+
+// growthTrack is what uptop remembers of a process between samples
+type growthTrack struct {
+	// Memory when the process was first seen and at the last sample, and
+	// when that was taken
+	first, last footprint
+	lastTime    time.Time
+	// Change over the last sample, and of USS plus SwapPSS in kB per second
+	delta footprint
+	rate  float64
+	// Recent USS for the leak detector, and the line fitted through it
+	samples []usage
+	trend   Trend
+	// Memory at each of the last historyLen samples, oldest first
+	history []footprint
+	// Last sample the process was seen in
+	gen int
+}
+
+// Growth of every process seen, by identity, and the number of samples
+var (
+	tracks   = make(map[procKey]*growthTrack)
+	trackGen int
+)
+
+// key identifies the process across refreshes
+func (p *Process) key() procKey {
+	return procKey{PID: strconv.Itoa(p.PID), Start: p.Start}
+}
+
+// footprint is the memory of the process to compare across refreshes
+func (p *Process) footprint() footprint {
+	return footprint{PSS: p.PSS, USS: p.USS, Swap: p.Swap}
+}
+
+// trackGrowth samples the memory of each process: how much it grew since
+// the last sample and since it was first seen, how fast, and its USS trend.
+// It's called once per tick rather than on every refresh, so that samples
+// are evenly spaced. Processes that have gone are forgotten.
+func trackGrowth(procs []*Process) {
+	trackGen++
+	when := sampleTime()
+	for _, p := range procs {
+		now := p.footprint()
+		t, ok := tracks[p.key()]
+		if !ok {
+			t = &growthTrack{first: now, last: now, lastTime: when}
+			tracks[p.key()] = t
+		}
+		t.delta = footprint{PSS: now.PSS - t.last.PSS, USS: now.USS - t.last.USS, Swap: now.Swap - t.last.Swap}
+		t.rate = 0
+		if secs := when.Sub(t.lastTime).Seconds(); secs > 0 {
+			t.rate = float64(t.delta.USS+t.delta.Swap) / secs
+		}
+		t.last, t.lastTime = now, when
+		t.gen = trackGen
+		t.record(now)
+		if !p.Partial {
+			t.addSample(when, now.USS)
+			t.trend = fitTrend(t.samples)
+		}
+	}
+	for key, t := range tracks {
+		if t.gen != trackGen {
+			delete(tracks, key)
+		}
+	}
+	applyGrowth(procs)
+}
+
+// applyGrowth fills in the growth of each process as of the last sample
+func applyGrowth(procs []*Process) {
+	for _, p := range procs {
+		t, ok := tracks[p.key()]
+		if !ok {
+			continue
+		}
+		p.DeltaPSS, p.DeltaUSS, p.DeltaSwap = t.delta.PSS, t.delta.USS, t.delta.Swap
+		p.GrowthPSS, p.GrowthUSS, p.GrowthSwap = t.last.PSS-t.first.PSS, t.last.USS-t.first.USS, t.last.Swap-t.first.Swap
+		p.Rate = t.rate
+		p.Trend = t.trend
+	}
+}
+
+// Number of samples of history kept for each process, one a second
+var historyLen = 120
+
+// record adds a sample to the history, dropping the oldest beyond
+// historyLen
+func (t *growthTrack) record(f footprint) {
+	if len(t.history) >= historyLen {
+		n := copy(t.history, t.history[len(t.history)-historyLen+1:])
+		t.history = t.history[:n]
+	}
+	t.history = append(t.history, f)
+}
+
+// historyOf returns the memory of the process at recent samples
+func historyOf(p *Process) []footprint {
+	if t, ok := tracks[p.key()]; ok {
+		return t.history
+	}
+	return nil
+}
+
+// growing is the sort value of the fastest growing processes
+func (p *Process) growing() float64 {
+	return p.Rate
+}
+
+// growth is the sort value of the processes that grew most
+func (p *Process) growth() int {
+	return p.GrowthUSS + p.GrowthSwap
+}
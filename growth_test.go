@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrackGrowth(t *testing.T) {
+	defer func(key string) {
+		replay, sortKey = nil, key
+		tracks = make(map[procKey]*growthTrack)
+	}(sortKey)
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	replay = &archive{frames: []*frame{{Time: t0}, {Time: t0.Add(time.Second)}, {Time: t0.Add(3 * time.Second)}}}
+	sortKey = sortGrowing
+
+	// USS and SwapPSS of two processes at each frame. The first grows by
+	// 40 kB over the two seconds to the last frame, the second by 10 kB
+	// over the same time, having grown by 30 kB in the second before.
+	uss := [][2]int{{100, 1000}, {110, 1030}, {150, 1040}}
+	procs := []*Process{{PID: 10, Start: 5}, {PID: 20, Start: 6}}
+	for i := range replay.frames {
+		replay.cur = i
+		for j, p := range procs {
+			p.USS = uss[i][j]
+		}
+		trackGrowth(procs)
+	}
+	if a, b := procs[0].Rate, procs[1].Rate; a != 20 || b != 5 {
+		t.Errorf("rates = %v, %v kB/s; want 20, 5", a, b)
+	}
+	if d, g := procs[1].DeltaUSS, procs[1].GrowthUSS; d != 10 || g != 40 {
+		t.Errorf("second process grew %d kB over the last sample and %d in all, want 10 and 40", d, g)
+	}
+
+	// Refreshes between samples show the growth as of the last sample
+	fresh := []*Process{{PID: 20, Start: 6, USS: 2000}, {PID: 10, Start: 5, USS: 150}}
+	applyGrowth(fresh)
+	sortProcesses(fresh)
+	if fresh[0].PID != 10 || fresh[0].Rate != 20 || fresh[1].DeltaUSS != 10 {
+		t.Errorf("after a refresh between samples, %d grows at %v kB/s and %d grew %d kB; want 10 at 20 and 20 by 10",
+			fresh[0].PID, fresh[0].Rate, fresh[1].PID, fresh[1].DeltaUSS)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	defer func(n int) {
+		replay, historyLen = nil, n
+		tracks = make(map[procKey]*growthTrack)
+	}(historyLen)
+	historyLen = 3
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	replay = &archive{}
+	p := &Process{PID: 10, Start: 5}
+	for i := 1; i <= 5; i++ {
+		replay.frames = append(replay.frames, &frame{Time: t0.Add(time.Duration(i) * time.Second)})
+		replay.cur = i - 1
+		p.PSS = i
+		trackGrowth([]*Process{p})
+		// Refreshes between samples, like key presses, add nothing
+		applyGrowth([]*Process{p})
+		applyGrowth([]*Process{p})
+	}
+	want := []footprint{{PSS: 3}, {PSS: 4}, {PSS: 5}}
+	if got := historyOf(p); !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// How far back the leak detector looks, and how much a process's USS must
+// grow across it before it counts as a leak rather than noise, in kB
+var (
+	leakWindow = 10 * time.Minute
+	leakNoise  = 1024
+)
+
+// Most USS samples kept per process. Samples closer together than the
+// window divided by this are skipped, which bounds the memory used.
+const leakSamples = 120
+
+// usage is a process's USS at one point in time
+type usage struct {
+	Time time.Time
+	USS  int
+}
+
+// Trend is the straight line fitted through a process's recent USS
+type Trend struct {
+	// Projected growth in kB per hour
+	PerHour int
+	// Time covered by the samples, and their number
+	Span    time.Duration
+	Samples int
+	// Spread of the samples around the line, in kB
+	Noise int
+	// Leaking is set when USS has grown steadily across the whole window
+	Leaking bool
+}
+
+// sampleTime is now, or when the frame being replayed was captured
+func sampleTime() time.Time {
+	if replay != nil {
+		return replay.Time()
+	}
+	return time.Now()
+}
+
+// addSample records a USS sample, dropping those older than the window
+func (t *growthTrack) addSample(now time.Time, uss int) {
+	// Stepping back through a replay starts over
+	if n := len(t.samples); n > 0 && now.Before(t.samples[n-1].Time) {
+		t.samples = nil
+	}
+	if n := len(t.samples); n > 0 && now.Sub(t.samples[n-1].Time) < leakWindow/leakSamples {
+		return
+	}
+	t.samples = append(t.samples, usage{Time: now, USS: uss})
+	old := 0
+	for old < len(t.samples) && now.Sub(t.samples[old].Time) > leakWindow {
+		old++
+	}
+	t.samples = t.samples[old:]
+}
+
+// fitTrend fits a least squares line through USS samples. A process leaks
+// when the samples cover nearly the whole window, the line rises by more
+// than the noise tolerance across it and the samples stay close to the
+// line, so that a single large allocation doesn't count. Wherever in the
+// window a single step falls, the samples stray from the line by at least
+// a sixth of its rise, so the spread must stay under an eighth of it.
+func fitTrend(samples []usage) Trend {
+	tr := Trend{Samples: len(samples)}
+	if len(samples) < 3 {
+		return tr
+	}
+	t0 := samples[0].Time
+	tr.Span = samples[len(samples)-1].Time.Sub(t0)
+	n := float64(len(samples))
+	var sx, sy, sxx, sxy float64
+	for _, s := range samples {
+		x, y := s.Time.Sub(t0).Seconds(), float64(s.USS)
+		sx += x
+		sy += y
+		sxx += x * x
+		sxy += x * y
+	}
+	den := n*sxx - sx*sx
+	if den == 0 {
+		return tr
+	}
+	slope := (n*sxy - sx*sy) / den
+	intercept := (sy - slope*sx) / n
+	var ss float64
+	for _, s := range samples {
+		d := float64(s.USS) - (intercept + slope*s.Time.Sub(t0).Seconds())
+		ss += d * d
+	}
+	tr.Noise = int(math.Sqrt(ss / n))
+	tr.PerHour = int(slope * 3600)
+	growth := int(slope * tr.Span.Seconds())
+	tr.Leaking = tr.Span >= leakWindow*9/10 && growth > leakNoise && tr.Noise*8 < growth
+	return tr
+}
+
+// sortLeaks puts the leaking processes first, then sorts by growth per hour
+func sortLeaks(procs []*Process) {
+	sort.SliceStable(procs, func(i, j int) bool {
+		a, b := procs[i].Trend, procs[j].Trend
+		if a.Leaking != b.Leaking {
+			return a.Leaking
+		}
+		return a.PerHour > b.PerHour
+	})
+}
+
+// Formats the leak detector's view of the processes for the termui table.
+// Processes without smaps have no USS to follow and show dashes.
+func leaksFormat(procs []*Process) [][]string {
+	tab := [][]string{{"PID", "Name", userTitle(), "USS", "kB/hour", "Noise", "Watched", "Leak", "Command"},
+		{"---", "----", "----", "---", "-------", "-----", "-------", "----", "-------"}}
+	for _, p := range procs {
+		leak := ""
+		if p.Trend.Leaking {
+			leak = "yes"
+		}
+		perHour, noise, watched := fmt.Sprintf("%+d", p.Trend.PerHour), strconv.Itoa(p.Trend.Noise),
+			p.Trend.Span.Round(time.Second).String()
+		if p.Partial {
+			perHour, noise, watched = "-", "-", "-"
+		}
+		tab = append(tab, []string{strconv.Itoa(p.PID), p.Name, p.User, partialCell(p, p.USS),
+			perHour, noise, watched, leak, p.Command})
+	}
+	return tab
+}
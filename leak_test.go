@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// samplesEvery makes n samples step apart, with USS from f of the sample's
+// offset in seconds
+func samplesEvery(n int, step time.Duration, f func(secs float64) int) []usage {
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	var samples []usage
+	for i := 0; i < n; i++ {
+		offset := time.Duration(i) * step
+		samples = append(samples, usage{Time: t0.Add(offset), USS: f(offset.Seconds())})
+	}
+	return samples
+}
+
+func TestFitTrend(t *testing.T) {
+	defer func(window time.Duration, noise int) { leakWindow, leakNoise = window, noise }(leakWindow, leakNoise)
+	leakWindow, leakNoise = 10*time.Minute, 1024
+	tests := []struct {
+		name    string
+		samples []usage
+		perHour int
+		noise   int
+		leaking bool
+	}{
+		{"too few", samplesEvery(2, 5*time.Second, func(s float64) int { return int(s) }), 0, 0, false},
+		// 2 kB a second across the window
+		{"steady", samplesEvery(120, 5*time.Second, func(s float64) int { return 50000 + int(2*s) }), 7200, 0, true},
+		{"steady but young", samplesEvery(60, 5*time.Second, func(s float64) int { return 50000 + int(2*s) }), 7200, 0, false},
+		// Half a kB a second, which is under the noise tolerance
+		{"slow", samplesEvery(120, 5*time.Second, func(s float64) int { return 50000 + int(s/2) }), 1800, 0, false},
+		{"flat", samplesEvery(120, 5*time.Second, func(s float64) int { return 50000 + int(s)%2 }), 0, 0, false},
+		{"shrinking", samplesEvery(120, 5*time.Second, func(s float64) int { return 50000 - int(2*s) }), -7200, 0, false},
+		// One large allocation halfway through the window
+		{"step", samplesEvery(120, 5*time.Second, func(s float64) int {
+			if s < 300 {
+				return 50000
+			}
+			return 60000
+		}), 90000, 2500, false},
+		{"late step", samplesEvery(120, 5*time.Second, func(s float64) int {
+			if s < 540 {
+				return 50000
+			}
+			return 60000
+		}), 32000, 2600, false},
+		// A leak with allocations coming and going around it
+		{"noisy leak", samplesEvery(120, 5*time.Second, func(s float64) int {
+			return 50000 + int(2*s) + int(s)%15*10
+		}), 7200, 40, true},
+		// Samples taken at the same time can't be fitted
+		{"same time", samplesEvery(5, 0, func(s float64) int { return 1 }), 0, 0, false},
+	}
+	for _, tt := range tests {
+		tr := fitTrend(tt.samples)
+		if tr.Samples != len(tt.samples) || tr.Leaking != tt.leaking {
+			t.Errorf("%s: %d samples, leaking %v; want %d, %v", tt.name, tr.Samples, tr.Leaking, len(tt.samples), tt.leaking)
+		}
+		// The fit is approximate where the samples are rounded or jump
+		if d := abs(tr.PerHour - tt.perHour); d > 50+abs(tt.perHour)/50 {
+			t.Errorf("%s: %+d kB/hour, want about %+d", tt.name, tr.PerHour, tt.perHour)
+		}
+		if d := tr.Noise - tt.noise; d < -tt.noise/5-1 || d > tt.noise/5+1 {
+			t.Errorf("%s: noise %d kB, want about %d", tt.name, tr.Noise, tt.noise)
+		}
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func TestAddSample(t *testing.T) {
+	defer func(window time.Duration) { leakWindow = window }(leakWindow)
+	leakWindow = 10 * time.Minute
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tr := &growthTrack{}
+	// One sample a second for 15 minutes keeps one every 5 seconds of the
+	// last 10 minutes
+	for i := 0; i < 900; i++ {
+		tr.addSample(t0.Add(time.Duration(i)*time.Second), i)
+	}
+	if n := len(tr.samples); n != leakSamples+1 {
+		t.Errorf("kept %d samples, want %d", n, leakSamples+1)
+	}
+	first, last := tr.samples[0].Time, tr.samples[len(tr.samples)-1].Time
+	if last.Sub(first) > leakWindow {
+		t.Errorf("kept samples from %s to %s, more than the window", first, last)
+	}
+	// Stepping back through a replay starts over
+	tr.addSample(t0, 0)
+	if n := len(tr.samples); n != 1 {
+		t.Errorf("kept %d samples after stepping back, want 1", n)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Library sums the mappings of one backing file across every process
+type Library struct {
+	Path                      string
+	RSS, PSS, Private, Shared int
+	// Users holds each process mapping the file and its share of it
+	Users []*LibraryUser
+}
+
+// LibraryUser is the part of a Library mapped by one process
+type LibraryUser struct {
+	*Process
+	RSS, PSS, Private, Shared int
+}
+
+// GetLibraries reads the mappings of each process and groups the file
+// backed ones by path
+func GetLibraries(procs []*Process) []*Library {
+	byPath := make(map[string]*Library)
+	for _, p := range procs {
+		maps, err := readMappings(p.Basepath)
+		if err != nil {
+			continue
+		}
+		users := make(map[string]*LibraryUser)
+		for _, m := range maps {
+			if !strings.HasPrefix(m.Path, "/") {
+				continue
+			}
+			lib, ok := byPath[m.Path]
+			if !ok {
+				lib = &Library{Path: m.Path}
+				byPath[m.Path] = lib
+			}
+			u, ok := users[m.Path]
+			if !ok {
+				u = &LibraryUser{Process: p}
+				users[m.Path] = u
+				lib.Users = append(lib.Users, u)
+			}
+			private := m.Counters["Private_Clean"] + m.Counters["Private_Dirty"]
+			shared := m.Counters["Shared_Clean"] + m.Counters["Shared_Dirty"]
+			u.RSS += m.Counters["Rss"]
+			u.PSS += m.Counters["Pss"]
+			u.Private += private
+			u.Shared += shared
+			lib.RSS += m.Counters["Rss"]
+			lib.PSS += m.Counters["Pss"]
+			lib.Private += private
+			lib.Shared += shared
+		}
+	}
+	libs := make([]*Library, 0, len(byPath))
+	for _, lib := range byPath {
+		libs = append(libs, lib)
+	}
+	sort.Slice(libs, func(i, j int) bool { return libs[i].Path < libs[j].Path })
+	sortLibraries(libs, sortKey)
+	return libs
+}
+
+// sortLibraries orders libraries, and the processes mapping each of them,
+// by the given sort key. Name sorts ascending, uss sorts by private memory,
+// all else sorts descending.
+func sortLibraries(libs []*Library, key string) {
+	for _, lib := range libs {
+		sortLibraryUsers(lib.Users, key)
+	}
+	switch key {
+	case "name":
+		sort.SliceStable(libs, func(i, j int) bool { return libs[i].Path < libs[j].Path })
+	case "rss":
+		sort.SliceStable(libs, func(i, j int) bool { return libs[i].RSS > libs[j].RSS })
+	case "pss":
+		sort.SliceStable(libs, func(i, j int) bool { return libs[i].PSS > libs[j].PSS })
+	case "uss":
+		sort.SliceStable(libs, func(i, j int) bool { return libs[i].Private > libs[j].Private })
+	}
+}
+
+// sortLibraryUsers orders the processes mapping a library
+func sortLibraryUsers(users []*LibraryUser, key string) {
+	switch key {
+	case "name":
+		sort.SliceStable(users, func(i, j int) bool { return users[i].Name < users[j].Name })
+	case "rss":
+		sort.SliceStable(users, func(i, j int) bool { return users[i].RSS > users[j].RSS })
+	case "pss":
+		sort.SliceStable(users, func(i, j int) bool { return users[i].PSS > users[j].PSS })
+	case "uss":
+		sort.SliceStable(users, func(i, j int) bool { return users[i].Private > users[j].Private })
+	}
+}
+
+// findLibrary returns the library with the given path
+func findLibrary(libs []*Library, path string) *Library {
+	for _, lib := range libs {
+		if lib.Path == path {
+			return lib
+		}
+	}
+	return nil
+}
+
+// Formats the libraries for the termui table
+func libsFormat(libs []*Library) [][]string {
+	tab := [][]string{{"Procs", "Private", "Shared", "PSS", "RSS", "File"},
+		{"-----", "-------", "------", "---", "---", "----"}}
+	for _, lib := range libs {
+		tab = append(tab, []string{strconv.Itoa(len(lib.Users)), strconv.Itoa(lib.Private),
+			strconv.Itoa(lib.Shared), strconv.Itoa(lib.PSS), strconv.Itoa(lib.RSS), lib.Path})
+	}
+	return tab
+}
+
+// Formats the processes mapping one library for the termui table
+func libUsersFormat(lib *Library) [][]string {
+	tab := [][]string{{"PID", "Name", userTitle(), "Private", "Shared", "PSS", "RSS", "Command"},
+		{"---", "----", "----", "-------", "------", "---", "---", "-------"}}
+	for _, u := range lib.Users {
+		tab = append(tab, []string{strconv.Itoa(u.PID), u.Name, u.User, strconv.Itoa(u.Private),
+			strconv.Itoa(u.Shared), strconv.Itoa(u.PSS), strconv.Itoa(u.RSS), u.Command})
+	}
+	return tab
+}
+
+// Print header and then the memory attributed to each library
+func printLibraries(libs []*Library) {
+	fmt.Printf("%6s  %8s  %8s  %8s  %8s  %s\n", "Procs", "Private", "Shared", "PSS", "RSS", "File")
+	for _, lib := range libs {
+		fmt.Printf("%6d  %8d  %8d  %8d  %8d  %s\n",
+			len(lib.Users), lib.Private, lib.Shared, lib.PSS, lib.RSS, lib.Path)
+	}
+}
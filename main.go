@@ -1,46 +1,95 @@
 package main
 
 import (
-	"bufio"
 	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"os"
 	"os/user"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
-	"time"
-
-	ui "github.com/gizak/termui"
-	"github.com/gizak/termui/widgets"
 )
 
 // Program version
 const version = "1.0"
 
-// UID->username map cache
-var ucache = make(map[uint32]string)
+// UID->username map cache, guarded by ucacheMu as processes are read
+// concurrently
+var (
+	ucache   = make(map[uint32]string)
+	ucacheMu sync.Mutex
+)
 
-// Regex to get the process name out of stat file
-var namergx = regexp.MustCompile(`\((.*?)\)`)
+// Number of processes read at once
+var workers = runtime.NumCPU()
 
 // Default sort key
 var sortKey = "rss"
 
+// Show kernel threads, zombies and processes with an empty cmdline
+var showAll = false
+
 // Process holds information about a process
 type Process struct {
 	Basepath            string
-	PID                 int
+	PID, PPID           int
 	Name, User, Command string
+	// Start is the start time from stat, which with PID identifies the
+	// process across refreshes
+	Start uint64
+	// UIDs from status; User is the name of the one chosen with -user, or
+	// the UID and its name inside the process's container
+	UIDs UIDs
+	// Cgroup is the cgroup v2 path from /proc/<pid>/cgroup
+	Cgroup string
+	// Systemd slice, unit and login session ID derived from the cgroup.
+	// Manager is the user@ service of units run by a user's systemd.
+	Slice, Unit, Session, Manager string
+	// Container is the container the process runs in, if any
+	Container Container
+	// Exe is the resolved /proc/<pid>/exe, empty when it can't be read
+	Exe string
+	// State is the one letter state from stat, e.g. R, S, D or Z
+	State string
+	// Kernel is set for kernel threads, which have no userspace memory
+	Kernel              bool
 	RSS, PSS, USS, Swap int
+	// The remaining smaps counters, summed over every mapping. RawSwap is
+	// the Swap counter; Swap above holds SwapPss.
+	SharedClean, SharedDirty, PrivateClean, PrivateDirty int
+	Referenced, Anonymous, LazyFree                      int
+	AnonHugePages, ShmemPmdMapped, FilePmdMapped         int
+	SharedHugetlb, PrivateHugetlb                        int
+	RawSwap, Locked                                      int
+	PssAnon, PssFile, PssShmem                           int
+	// PssSplit is set when smaps broke PSS down into Pss_Anon, Pss_File and
+	// Pss_Shmem, which only newer kernels' smaps_rollup does
+	PssSplit bool
+	// Shared and text sizes from statm
+	Shared, Text int
+	// Source is the file the memory totals came from: smaps_rollup or smaps,
+	// or statm for partially read processes
+	Source string
+	// Growth of PSS, USS and SwapPSS over the last sample and since the
+	// process was first seen, and of USS plus SwapPSS in kB per second over
+	// the last sample
+	DeltaPSS, DeltaUSS, DeltaSwap    int
+	GrowthPSS, GrowthUSS, GrowthSwap int
+	Rate                             float64
+	// Trend of USS over the leak detector's window
+	Trend Trend
+	// Partial is set when smaps couldn't be read, so that PSS, USS and
+	// SwapPSS are unknown and RSS, Anonymous, RawSwap and Locked come from
+	// statm and status
+	Partial bool
 }
 
-// scrapeSmaps sums select memory fields from /proc/<int>/smaps
+// scrapeSmaps sums select memory fields from /proc/<int>/smaps_rollup, or
+// from /proc/<int>/smaps when the kernel doesn't provide the rollup
 func (p *Process) scrapeSmaps() error {
 	if p.Basepath == "" {
 		return fmt.Errorf("no path for Process")
@@ -51,68 +100,152 @@ func (p *Process) scrapeSmaps() error {
 		return err
 	}
 	p.PID = pid
-	smap := filepath.Join(p.Basepath, "smaps")
-	file, err := os.Open(smap)
+	totals, source, err := readSmapsTotals(p.Basepath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
+	p.Source = source
+	p.RSS = totals["Rss"]
+	p.PSS = totals["Pss"]
+	p.Swap = totals["SwapPss"]
+	p.USS = totals["Private_Clean"] + totals["Private_Dirty"]
+	p.SharedClean = totals["Shared_Clean"]
+	p.SharedDirty = totals["Shared_Dirty"]
+	p.PrivateClean = totals["Private_Clean"]
+	p.PrivateDirty = totals["Private_Dirty"]
+	p.Referenced = totals["Referenced"]
+	p.Anonymous = totals["Anonymous"]
+	p.LazyFree = totals["LazyFree"]
+	p.AnonHugePages = totals["AnonHugePages"]
+	p.ShmemPmdMapped = totals["ShmemPmdMapped"]
+	p.FilePmdMapped = totals["FilePmdMapped"]
+	p.SharedHugetlb = totals["Shared_Hugetlb"]
+	p.PrivateHugetlb = totals["Private_Hugetlb"]
+	p.RawSwap = totals["Swap"]
+	p.Locked = totals["Locked"]
+	p.PssAnon = totals["Pss_Anon"]
+	p.PssFile = totals["Pss_File"]
+	p.PssShmem = totals["Pss_Shmem"]
+	_, p.PssSplit = totals["Pss_File"]
+	return nil
+}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		p.RSS += getSmapMem(line, "Rss")
-		p.PSS += getSmapMem(line, "Pss")
-		p.Swap += getSmapMem(line, "SwapPss")
-		p.USS += getSmapMem(line, "Private_Clean")
-		p.USS += getSmapMem(line, "Private_Dirty")
+// scrapeStatus fills in what it can from statm and status, which anyone can
+// read, when smaps can't be read
+func (p *Process) scrapeStatus(statm procStatm) error {
+	status, err := readStatus(p.Basepath)
+	if err != nil {
+		return err
 	}
+	p.Partial = true
+	p.Source = sourceStatm
+	p.RSS = statm.Resident * os.Getpagesize() / 1024
+	p.Anonymous = statusKB(status["RssAnon"])
+	p.RawSwap = statusKB(status["VmSwap"])
+	p.Locked = statusKB(status["VmLck"])
 	return nil
 }
 
-// PopulateInfo fills in the Process attributes
-func (p *Process) PopulateInfo() error {
-	if err := p.scrapeSmaps(); err != nil {
-		return err
+// PopulateInfo fills in the Process attributes from its stat and the rest
+// of its proc files. Exe is only looked up when it isn't already set. The
+// UIDs are read every time, since a process can change them at any point.
+func (p *Process) PopulateInfo(stat *procStat) error {
+	p.Name = stat.Name
+	p.State = stat.State
+	p.PPID = stat.PPID
+	p.Start = stat.Start
+	if p.Exe == "" {
+		p.Exe, _ = fsys.Readlink(filepath.Join(p.Basepath, "exe"))
 	}
-	p.Name = getProcName(p.Basepath)
-	user, err := lookupUsername(p.Basepath)
+	cgroup, paths := readCgroups(p.Basepath)
+	p.Cgroup = cgroup
+	p.Container = parseContainer(paths)
+	p.Slice, p.Unit, p.Session, p.Manager = parseSystemd(paths)
+	p.Kernel = stat.isKernelThread()
+	statm, statmErr := readStatm(p.Basepath)
+	p.Shared = statm.Shared * os.Getpagesize() / 1024
+	p.Text = statm.Text * os.Getpagesize() / 1024
+	// Zombies and kernel threads have no smaps to read, and other users'
+	// smaps can't be read without privileges
+	if err := p.scrapeSmaps(); err != nil && !p.Kernel && !p.IsZombie() {
+		if statmErr != nil {
+			return err
+		}
+		if err := p.scrapeStatus(statm); err != nil {
+			return err
+		}
+	}
+	if p.Command == "" {
+		p.Command = "[" + p.Name + "]"
+		if p.IsZombie() {
+			p.Command += " <defunct>"
+		}
+	}
+	uids, err := readUIDs(p.Basepath)
 	if err != nil {
 		return err
 	}
-	p.User = user
+	p.UIDs = uids
+	uid := uids.Pick(userUID)
+	p.User = lookupUsername(uid)
+	// Containers show the host UID and who it is inside
+	if local, ok := localUsername(p.Basepath, uid); ok {
+		p.User = fmt.Sprintf("%d(%s)", uid, local)
+	}
 	return nil
 }
 
-// lookupUsername looks up username for uid if not already in cache
-func lookupUsername(file string) (string, error) {
-	fileInfo, err := os.Stat(file)
-	if err != nil {
-		return "", err
-	}
-	uid := fileInfo.Sys().(*syscall.Stat_t).Uid
-	if ucache[uid] != "" {
-		return ucache[uid], nil
+// IsZombie reports whether the process has exited but not been reaped
+func (p *Process) IsZombie() bool {
+	return p.State == "Z"
+}
+
+// readUIDs reads the UIDs of the process at path from its status, falling
+// back to the owner of its directory when status can't be read
+func readUIDs(path string) (UIDs, error) {
+	if status, err := readStatus(path); err == nil {
+		if uids, err := parseUIDs(status["Uid"]); err == nil {
+			return uids, nil
+		}
 	}
-	u, err := user.LookupId(strconv.FormatUint(uint64(uid), 10))
+	fileInfo, err := fsys.Stat(path)
 	if err != nil {
-		return "", err
+		return UIDs{}, err
 	}
-	ucache[uid] = u.Username
-	return u.Username, nil
+	uid := fileInfo.Sys().(*syscall.Stat_t).Uid
+	return UIDs{Real: uid, Effective: uid, Saved: uid}, nil
 }
 
-// getSmapMem is a helper function to read particular values from smaps
-func getSmapMem(line, mment string) int {
-	if strings.HasPrefix(line, mment) {
-		f, err := strconv.Atoi(strings.Fields(line)[1])
-		if err != nil {
-			return 0
+// lookupUsername looks up username for uid if not already in cache. UIDs
+// with no passwd entry, common in containers, show as the number, which is
+// cached too so the lookup isn't repeated every refresh. With a procRoot
+// other than /proc the names come from the passwd file of the host it
+// belongs to rather than our own, and in replay from the archive, which
+// has a name for every UID it saw.
+func lookupUsername(uid uint32) string {
+	ucacheMu.Lock()
+	name, ok := ucache[uid]
+	ucacheMu.Unlock()
+	if ok {
+		return name
+	}
+	name = strconv.FormatUint(uint64(uid), 10)
+	switch {
+	case replay != nil:
+		// Our own users say nothing about the captured host
+	case procRoot != "/proc":
+		if local, ok := hostUsers()[uid]; ok {
+			name = local
+		}
+	default:
+		if u, err := user.LookupId(name); err == nil {
+			name = u.Username
 		}
-		return f
 	}
-	return 0
+	ucacheMu.Lock()
+	ucache[uid] = name
+	ucacheMu.Unlock()
+	return name
 }
 
 // Determine if it's a process dir by checking if the dirname is an int
@@ -125,25 +258,10 @@ func isProc(path string) bool {
 	return true
 }
 
-// Get process name from stat file
-func getProcName(path string) string {
-	namepath := filepath.Join(path, "stat")
-	statp, err := ioutil.ReadFile(namepath)
-	if err != nil {
-		// fmt.Printf("error reading stat [%v]\n", err)
-		return ""
-	}
-	match := namergx.FindStringSubmatch(string(statp))
-	if len(match) < 2 {
-		return ""
-	}
-	return match[1]
-}
-
 // Returns the process cmdline
 func getCmdline(path string) string {
 	cmdpath := filepath.Join(path, "cmdline")
-	cmdline, err := ioutil.ReadFile(cmdpath)
+	cmdline, err := readFile(cmdpath)
 	if err != nil {
 		//fmt.Printf("read error [%v]\n", err)
 		return ""
@@ -152,49 +270,114 @@ func getCmdline(path string) string {
 	return strings.Replace(cmdstring, "\x00", " ", -1)
 }
 
-// GetProcesses returns a collection of Processes
+// GetProcesses returns a collection of Processes. They're read by a pool
+// of workers, but come back in the same order for the same sort key.
 func GetProcesses(rootpath string) []*Process {
 	box := []*Process{}
-	dirs, err := ioutil.ReadDir(rootpath)
+	dirs, err := fsys.ReadDir(rootpath)
 	if err != nil {
 		fmt.Printf("readdir error [%v]\n", err)
 	}
+	var paths []string
 	for _, f := range dirs {
 		fname := filepath.Join(rootpath, f.Name())
 		if isProc(fname) {
-			p, ok := processIt(fname)
-			if ok {
-				box = append(box, p)
+			paths = append(paths, fname)
+		}
+	}
+	// Each worker fills in the slots of the paths it takes, so the
+	// results stay in directory order
+	startRefresh()
+	defer pruneCache()
+	results := make([]*Process, len(paths))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(paths); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if p, ok := processIt(paths[i]); ok {
+					results[i] = p
+				}
 			}
+		}()
+	}
+	for i := range paths {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	for _, p := range results {
+		if p != nil {
+			box = append(box, p)
 		}
 	}
-	// Name sorts ascending, all else sorts descending
+	applyGrowth(box)
+	sortProcesses(box)
+	return box
+}
+
+// sortProcesses orders the processes by sortKey. Name sorts ascending, all
+// else sorts descending. Ties keep the order they're in, which is directory
+// order straight from GetProcesses.
+func sortProcesses(box []*Process) {
 	switch sortKey {
 	case "name":
-		sort.Slice(box, func(i, j int) bool { return box[i].Name < box[j].Name })
+		sort.SliceStable(box, func(i, j int) bool { return box[i].Name < box[j].Name })
 	case "rss":
-		sort.Slice(box, func(i, j int) bool { return box[i].RSS > box[j].RSS })
+		sort.SliceStable(box, func(i, j int) bool { return box[i].RSS > box[j].RSS })
 	case "pss":
-		sort.Slice(box, func(i, j int) bool { return box[i].PSS > box[j].PSS })
+		sort.SliceStable(box, func(i, j int) bool { return box[i].PSS > box[j].PSS })
 	case "uss":
-		sort.Slice(box, func(i, j int) bool { return box[i].USS > box[j].USS })
+		sort.SliceStable(box, func(i, j int) bool { return box[i].USS > box[j].USS })
 	case "swap":
-		sort.Slice(box, func(i, j int) bool { return box[i].Swap > box[j].Swap })
+		sort.SliceStable(box, func(i, j int) bool { return box[i].Swap > box[j].Swap })
+	case sortGrowing:
+		sort.SliceStable(box, func(i, j int) bool { return box[i].growing() > box[j].growing() })
+	case sortGrowth:
+		sort.SliceStable(box, func(i, j int) bool { return box[i].growth() > box[j].growth() })
+	default:
+		if c, ok := findColumn(sortKey); ok {
+			sort.SliceStable(box, func(i, j int) bool { return c.Value(box[i]) > c.Value(box[j]) })
+		}
 	}
-	return box
 }
 
-// processIt returns a populated Process pointer
+// processIt returns a populated Process pointer. Processes with an empty
+// cmdline, such as kernel threads and zombies, are skipped unless showAll.
+// Cached attributes are reused, and smaps is only re-read when the process
+// looks to have changed since the last refresh.
 func processIt(fpath string) (*Process, bool) {
-	cmdline := getCmdline(fpath)
-	if cmdline != "" {
-		p := &Process{Basepath: fpath, Command: cmdline}
-		if err := p.PopulateInfo(); err != nil {
+	stat, err := readStat(fpath)
+	if err != nil {
+		return nil, false
+	}
+	c, cached := cachedProcess(cacheKey(fpath, stat), stat.Name)
+	if !cached {
+		c.Cmdline = getCmdline(fpath)
+	}
+	if c.Cmdline == "" && !showAll {
+		return nil, false
+	}
+	signals := readSignals(fpath, stat)
+	if c.fresh(signals) {
+		return c.reuse(stat), true
+	}
+	// Processes can rewrite their argv at any point, so every full read
+	// re-reads the cmdline too
+	if cached {
+		c.Cmdline = getCmdline(fpath)
+		if c.Cmdline == "" && !showAll {
 			return nil, false
 		}
-		return p, true
 	}
-	return nil, false
+	p := &Process{Basepath: fpath, Command: c.Cmdline, Exe: c.Exe}
+	if err := p.PopulateInfo(stat); err != nil {
+		return nil, false
+	}
+	c.remember(p, signals)
+	return p, true
 }
 
 // Fix this
@@ -208,104 +391,153 @@ func processIt(fpath string) (*Process, bool) {
 // 	}
 // }
 
-// Formats the processes for the termui table
-func tableFormat(a []*Process) [][]string {
-	tab := [][]string{{"PID", "Name", "User", "SwapPSS", "USS", "PSS", "RSS", "Command"},
-		{"---", "----", "----", "----", "---", "------", "---", "-------"}}
-	for _, p := range a {
-		tab = append(tab, []string{strconv.Itoa(p.PID), p.Name, p.User, strconv.Itoa(p.Swap),
-			strconv.Itoa(p.USS), strconv.Itoa(p.PSS), strconv.Itoa(p.RSS), p.Command})
+// partialCell formats a figure that only smaps provides, or "-" for
+// processes whose smaps couldn't be read
+func partialCell(p *Process, v int) string {
+	if p.Partial {
+		return "-"
 	}
-	return tab
+	return strconv.Itoa(v)
 }
 
-func runTermui() {
-	if err := ui.Init(); err != nil {
-		log.Fatalln("cannot initialize termui")
+// totalCell formats a sum of figures that only smaps provides, marked with
+// a * when it leaves out processes whose smaps couldn't be read
+func totalCell(v int, partial bool) string {
+	if partial {
+		return strconv.Itoa(v) + "*"
 	}
-	defer ui.Close()
-
-	procs := GetProcesses("/proc")
-
-	termWidth, termHeight := ui.TerminalDimensions()
-
-	tb := widgets.NewTable()
-	tb.RowSeparator = false
-	tb.SetRect(0, 0, termWidth, termHeight)
-	tb.BorderStyle = ui.NewStyle(ui.ColorBlack)
-	tb.Border = false
-	tb.ColumnWidths = []int{6, 18, 10, 8, 8, 8, 8, termWidth - 66}
-	tb.Rows = tableFormat(procs)
-
-	ui.Render(tb)
-
-	// Event Handlers
+	return strconv.Itoa(v)
+}
 
-	uiEvents := ui.PollEvents()
-	ticker := time.NewTicker(time.Second).C
-	for {
-		select {
-		case e := <-uiEvents:
-			switch e.ID {
-			case "q", "<C-c>":
-				return
-			case "r":
-				sortKey = "rss"
-				procs := GetProcesses("/proc")
-				tb.Rows = tableFormat(procs)
-				ui.Render(tb)
-			case "u":
-				sortKey = "uss"
-				procs := GetProcesses("/proc")
-				tb.Rows = tableFormat(procs)
-				ui.Render(tb)
-			case "p":
-				sortKey = "pss"
-				procs := GetProcesses("/proc")
-				tb.Rows = tableFormat(procs)
-				ui.Render(tb)
-			case "n":
-				sortKey = "name"
-				procs := GetProcesses("/proc")
-				tb.Rows = tableFormat(procs)
-				ui.Render(tb)
-			case "s":
-				sortKey = "swap"
-				procs := GetProcesses("/proc")
-				tb.Rows = tableFormat(procs)
-				ui.Render(tb)
-			case "<Resize>":
-				payload := e.Payload.(ui.Resize)
-				width, height := payload.Width, payload.Height
-				tb.SetRect(0, 0, width, height)
+// Formats the processes for the termui table
+func tableFormat(a []*Process) [][]string {
+	head := []string{"PID", "Name", userTitle(), "S", "SwapPSS", "USS", "PSS", "RSS"}
+	dash := []string{"---", "----", "----", "-", "----", "---", "------", "---"}
+	for _, c := range extraColumns {
+		head = append(head, c.Title)
+		dash = append(dash, strings.Repeat("-", len(c.Title)))
+	}
+	tab := [][]string{append(head, "Source", "Container", "Command"),
+		append(dash, "------", "---------", "-------")}
+	for _, p := range a {
+		row := []string{strconv.Itoa(p.PID), p.Name, p.User, p.State, partialCell(p, p.Swap),
+			partialCell(p, p.USS), partialCell(p, p.PSS), strconv.Itoa(p.RSS)}
+		for _, c := range extraColumns {
+			if partialColumns[c.Key] {
+				row = append(row, strconv.Itoa(c.Value(p)))
+			} else {
+				row = append(row, partialCell(p, c.Value(p)))
 			}
-
-		case <-ticker:
-			procs := GetProcesses("/proc")
-			tb.Rows = tableFormat(procs)
-			ui.Render(tb)
 		}
+		tab = append(tab, append(row, p.Source, p.Container.String(), p.Command))
 	}
+	return tab
 }
 
 func main() {
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Once running, hit n, r, p, s, or u to sort by Name, RSS, PSS, SwapPSS, or USS. RSS is default.\n")
+		fmt.Fprintf(os.Stderr, "Once running, hit n, r, p, s, or u to sort by Name, RSS, PSS, SwapPSS, or USS. RSS is default.\n"+
+			"Use the arrow keys to select a process, enter for everything about it, m to list its mappings\n"+
+			"and H its threads; escape goes back.\n"+
+			"Hit a to toggle showing kernel threads, zombies and processes with an empty cmdline.\n"+
+			"Hit o to cycle the sort through the optional columns shown.\n"+
+			"Hit f to sort by the fastest growing processes and b by those that grew most since uptop started.\n"+
+			"Hit t for the process tree with subtree totals; enter collapses or expands a process.\n"+
+			"Hit g to cycle through grouping the processes by "+strings.Join(groupNames(), ", ")+
+			" and back; c sorts groups by process count and enter lists a group's processes.\n"+
+			"Hit w for a report of where MemTotal went, like -report.\n"+
+			"Sparklines below the table show the selected process's PSS, USS and SwapPSS; v hides them.\n"+
+			"Hit L for the leak detector, which lists the processes whose USS grew steadily over -leak-window.\n"+
+			"Hit l to list mapped files across all processes and enter to see which processes map one.\n"+
+			"When replaying, P pauses, comma and period step a frame and < and > seek ten frames.\n")
 		flag.PrintDefaults()
 		os.Exit(0)
 	}
 	wantVersion := flag.Bool("version", false, "Print the version")
 	// wantOnce := flag.Bool("once", false, "Print table once and exit")
-	flag.StringVar(&sortKey, "sort", "rss", "Start sorted by name, rss, pss, swap, or uss")
+	flag.BoolVar(&showAll, "all", false, "Also show kernel threads, zombies and processes with an empty cmdline")
+	wantLibs := flag.Bool("libs", false, "Print the memory attributed to each mapped file and exit")
+	flag.StringVar(&sortKey, "sort", "rss", "Start sorted by name, rss, pss, swap, uss, growing, growth, or any optional column")
+	columns := flag.String("columns", defaultColumns, "Comma separated optional columns to show, or all or none: "+
+		strings.Join(columnKeys(), ", "))
+	flag.StringVar(&procRoot, "proc-root", procRoot, "Where procfs is mounted, e.g. /host/proc in a container")
+	wantReport := flag.Bool("report", false, "Print where MemTotal went and exit")
+	flag.StringVar(&userUID, "user", userUID, "Show the user of the real, effective or saved UID")
+	flag.BoolVar(&containerUsers, "container-users", false,
+		"Name container users from the container's own /etc/passwd; needs root")
+	flag.StringVar(&groupBy, "group", "", "Start grouped by "+strings.Join(groupNames(), ", "))
+	flag.StringVar(&cgroupRoot, "cgroup-root", cgroupRoot, "Where the cgroup v2 hierarchy is mounted")
+	flag.IntVar(&workers, "workers", workers, "Number of processes to read at once")
+	flag.DurationVar(&fullRefresh, "full-refresh", fullRefresh,
+		"Re-read the smaps of processes that look unchanged this often")
+	flag.IntVar(&historyLen, "history", historyLen, "Number of seconds of each process's memory to keep for its sparklines")
+	wantLeaks := flag.Bool("leaks", false, "Start in the leak detector")
+	flag.DurationVar(&leakWindow, "leak-window", leakWindow, "How long USS must grow steadily to count as a leak")
+	flag.IntVar(&leakNoise, "leak-noise", leakNoise, "Growth in kB over -leak-window below which it's noise")
+	capture := flag.String("capture", "", "Record what uptop reads every second into `FILE` until interrupted")
+	ticks := flag.Int("ticks", 0, "Stop -capture after this many seconds")
+	flag.BoolVar(&captureMaps, "capture-maps", false,
+		"Also record every process's full smaps with -capture, for the maps, threads and libs views")
+	replayFile := flag.String("replay", "", "Read a -capture `FILE` instead of the running system")
 	flag.Parse()
+	if _, ok := findGrouping(groupBy); groupBy != "" && !ok {
+		fmt.Fprintf(os.Stderr, "unknown grouping %q\n", groupBy)
+		os.Exit(1)
+	}
+	if _, ok := userTitles[userUID]; !ok {
+		fmt.Fprintf(os.Stderr, "unknown UID %q, expected real, effective or saved\n", userUID)
+		os.Exit(1)
+	}
+	cols, err := parseColumns(*columns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	extraColumns = cols
+	if workers < 1 {
+		workers = 1
+	}
+	if historyLen < 1 {
+		historyLen = 1
+	}
 	if *wantVersion {
 		fmt.Println(version)
 		os.Exit(0)
 	}
+	if *capture != "" {
+		if err := runCapture(*capture, *ticks); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if *replayFile != "" {
+		a, err := loadArchive(*replayFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		replay, fsys = a, a
+		incremental = false
+		procRoot, cgroupRoot = a.Meta.ProcRoot, a.Meta.CgroupRoot
+	}
+	if *wantLibs {
+		printLibraries(GetLibraries(GetProcesses(procRoot)))
+		os.Exit(0)
+	}
+	if *wantReport {
+		info, err := readMeminfo(procRoot)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		printReport(buildReport(info, GetProcesses(procRoot), readSocketMemory(procRoot)), info["MemTotal"])
+		os.Exit(0)
+	}
 	// if *wantOnce {
-	// 	procs := GetProcesses("/proc")
+	// 	procs := GetProcesses(procRoot)
 	// 	printProcesses(procs)
 	// 	os.Exit(0)
 	// }
-	runTermui()
+	runTermui(*wantLeaks)
 }
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// readMeminfo reads the kB figures of /proc/meminfo under rootpath, keyed by
+// name, e.g. MemTotal or Active(file)
+func readMeminfo(rootpath string) (map[string]int, error) {
+	file, err := fsys.Open(filepath.Join(rootpath, "meminfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		v, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		info[strings.TrimSuffix(fields[0], ":")] = v
+	}
+	return info, scanner.Err()
+}
+
+// humanKB formats a kB figure with a binary unit suffix, e.g. 1.5G
+func humanKB(kb int) string {
+	units := []string{"K", "M", "G", "T"}
+	sign := ""
+	if kb < 0 {
+		sign, kb = "-", -kb
+	}
+	v := float64(kb)
+	i := 0
+	for ; v >= 1024 && i < len(units)-1; i++ {
+		v /= 1024
+	}
+	if i == 0 || v >= 100 {
+		return fmt.Sprintf("%s%.0f%s", sign, v, units[i])
+	}
+	return fmt.Sprintf("%s%.1f%s", sign, v, units[i])
+}
+
+// percentOf returns part as a whole percentage of total
+func percentOf(part, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return part * 100 / total
+}
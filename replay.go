@@ -0,0 +1,289 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// The archive being replayed, if any
+var replay *archive
+
+// frame is everything uptop read during one captured tick
+type frame struct {
+	Time  time.Time
+	files map[string][]byte
+	links map[string]string
+	stats map[string]*archiveInfo
+	// Names of the entries of every directory, implied by the paths above
+	dirs map[string][]string
+}
+
+// archive replays a capture archive. It serves reads from the current
+// frame and is stepped through by the TUI.
+type archive struct {
+	Meta   captureMeta
+	frames []*frame
+	cur    int
+	Paused bool
+}
+
+// loadArchive reads a whole capture archive into memory
+func loadArchive(src string) (*archive, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(gz)
+
+	a := &archive{}
+	byNum := make(map[int]*frame)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Name == "meta.json" {
+			contents, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(contents, &a.Meta); err != nil {
+				return nil, err
+			}
+			if a.Meta.Version != captureVersion {
+				return nil, fmt.Errorf("%s: capture version %d, expected %d", src, a.Meta.Version, captureVersion)
+			}
+			continue
+		}
+		// frames/<n>/<kind>[/<path>]
+		parts := strings.SplitN(hdr.Name, "/", 4)
+		if len(parts) < 3 || parts[0] != "frames" {
+			continue
+		}
+		n, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+		f, ok := byNum[n]
+		if !ok {
+			f = &frame{
+				files: make(map[string][]byte),
+				links: make(map[string]string),
+				stats: make(map[string]*archiveInfo),
+				dirs:  make(map[string][]string),
+			}
+			byNum[n] = f
+		}
+		name := ""
+		if len(parts) == 4 {
+			name = "/" + parts[3]
+		}
+		switch parts[2] {
+		case "time":
+			contents, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			f.Time, _ = time.Parse(time.RFC3339Nano, string(contents))
+		case "users":
+			contents, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			for uid, user := range parseUsers(contents) {
+				ucache[uid] = user
+			}
+		case "file":
+			contents, err := ioutil.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+			f.files[name] = contents
+		case "link":
+			f.links[name] = hdr.Linkname
+		case "stat":
+			f.stats[name] = &archiveInfo{name: path.Base(name), mode: hdr.FileInfo().Mode(),
+				modTime: hdr.ModTime, uid: uint32(hdr.Uid), gid: uint32(hdr.Gid)}
+		}
+	}
+	if a.Meta.Version == 0 {
+		return nil, fmt.Errorf("%s: not an uptop capture", src)
+	}
+	var nums []int
+	for n := range byNum {
+		nums = append(nums, n)
+	}
+	sort.Ints(nums)
+	for _, n := range nums {
+		f := byNum[n]
+		f.indexDirs()
+		a.frames = append(a.frames, f)
+	}
+	if len(a.frames) == 0 {
+		return nil, fmt.Errorf("%s: no frames captured", src)
+	}
+	return a, nil
+}
+
+// indexDirs lists the entries of every directory leading to a path in f
+func (f *frame) indexDirs() {
+	seen := make(map[string]bool)
+	add := func(name string) {
+		for name != "/" && !seen[name] {
+			seen[name] = true
+			dir := path.Dir(name)
+			f.dirs[dir] = append(f.dirs[dir], path.Base(name))
+			name = dir
+		}
+	}
+	for name := range f.files {
+		add(name)
+	}
+	for name := range f.links {
+		add(name)
+	}
+	for name := range f.stats {
+		add(name)
+	}
+	for _, entries := range f.dirs {
+		sort.Strings(entries)
+	}
+}
+
+// Len is the number of frames in the archive
+func (a *archive) Len() int {
+	return len(a.frames)
+}
+
+// Index is the position of the current frame, from 0
+func (a *archive) Index() int {
+	return a.cur
+}
+
+// Time is when the current frame was captured
+func (a *archive) Time() time.Time {
+	return a.frames[a.cur].Time
+}
+
+// Seek moves delta frames forward, or backward when negative, stopping at
+// either end
+func (a *archive) Seek(delta int) {
+	a.cur += delta
+	if a.cur >= len(a.frames) {
+		a.cur = len(a.frames) - 1
+	}
+	if a.cur < 0 {
+		a.cur = 0
+	}
+}
+
+// Tick advances one frame unless paused, pausing at the last
+func (a *archive) Tick() {
+	if a.Paused {
+		return
+	}
+	a.Seek(1)
+	if a.cur == len(a.frames)-1 {
+		a.Paused = true
+	}
+}
+
+func (a *archive) Open(name string) (io.ReadCloser, error) {
+	contents, ok := a.frames[a.cur].files[name]
+	if !ok {
+		return nil, notCaptured("open", name)
+	}
+	return ioutil.NopCloser(bytes.NewReader(contents)), nil
+}
+
+func (a *archive) ReadDir(name string) ([]os.FileInfo, error) {
+	f := a.frames[a.cur]
+	entries, ok := f.dirs[path.Clean(name)]
+	if !ok {
+		return nil, notCaptured("readdir", name)
+	}
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fi, err := a.Stat(path.Join(name, entry))
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, fi)
+	}
+	return infos, nil
+}
+
+func (a *archive) Stat(name string) (os.FileInfo, error) {
+	f := a.frames[a.cur]
+	name = path.Clean(name)
+	if fi, ok := f.stats[name]; ok {
+		return fi, nil
+	}
+	if contents, ok := f.files[name]; ok {
+		return &archiveInfo{name: path.Base(name), mode: 0444, size: int64(len(contents))}, nil
+	}
+	if _, ok := f.dirs[name]; ok {
+		return &archiveInfo{name: path.Base(name), mode: os.ModeDir | 0555}, nil
+	}
+	return nil, notCaptured("stat", name)
+}
+
+func (a *archive) Readlink(name string) (string, error) {
+	link, ok := a.frames[a.cur].links[name]
+	if !ok {
+		return "", notCaptured("readlink", name)
+	}
+	return link, nil
+}
+
+func (a *archive) ReadRegular(name string, max int64) ([]byte, error) {
+	contents, ok := a.frames[a.cur].files[name]
+	if !ok {
+		return nil, notCaptured("open", name)
+	}
+	return readLimited(name, bytes.NewReader(contents), max)
+}
+
+// notCaptured is the error for a path the current frame doesn't hold
+func notCaptured(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+// archiveInfo describes a captured path. Sys returns a *syscall.Stat_t
+// holding the captured owner, as os.Stat does.
+type archiveInfo struct {
+	name     string
+	size     int64
+	mode     os.FileMode
+	modTime  time.Time
+	uid, gid uint32
+}
+
+func (fi *archiveInfo) Name() string       { return fi.name }
+func (fi *archiveInfo) Size() int64        { return fi.size }
+func (fi *archiveInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *archiveInfo) ModTime() time.Time { return fi.modTime }
+func (fi *archiveInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *archiveInfo) Sys() interface{} {
+	return &syscall.Stat_t{Uid: fi.uid, Gid: fi.gid}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// reportLine is one part of MemTotal in the reconciliation report
+type reportLine struct {
+	Label string
+	KB    int
+	Note  string
+}
+
+// buildReport accounts for every part of MemTotal. Userspace is the summed
+// PSS of the processes, so the file and shmem pages they map are taken out
+// of page cache and shmem to avoid counting them twice. Kernels that don't
+// break PSS down, and the smaps fallback, don't say how much of it is file
+// and shmem pages, so those stay in the cache figures and the report says
+// so. Whatever is left over once the kernel, cache, hugetlb and free memory
+// are added up is reported as unexplained.
+func buildReport(info map[string]int, procs []*Process, sockKB int) []reportLine {
+	var pss, pssFile, pssShmem, unsplit int
+	for _, p := range procs {
+		pss += p.PSS
+		pssFile += p.PssFile
+		pssShmem += p.PssShmem
+		if p.PSS > 0 && !p.PssSplit {
+			unsplit++
+		}
+	}
+	cacheNote := "Cached + Buffers, less shmem and file pages in process PSS"
+	shmemNote := "Shmem, less shmem pages in process PSS"
+	unexplainedNote := "drivers, unreadable processes and anything else"
+	if unsplit > 0 {
+		cacheNote = fmt.Sprintf("Cached + Buffers, less shmem; also holds file pages in the PSS of %d processes", unsplit)
+		shmemNote = fmt.Sprintf("Shmem; also holds shmem pages in the PSS of %d processes", unsplit)
+		unexplainedNote = "drivers, unreadable processes and anything else, less pages counted twice"
+	}
+	hugetlb, ok := info["Hugetlb"]
+	if !ok {
+		hugetlb = info["HugePages_Total"] * info["Hugepagesize"]
+	}
+	lines := []reportLine{
+		{"Userspace processes (PSS)", pss, fmt.Sprintf("summed over %d processes", len(procs))},
+		{"Page cache", info["Cached"] + info["Buffers"] - info["Shmem"] - pssFile, cacheNote},
+		{"tmpfs / shmem", info["Shmem"] - pssShmem, shmemNote},
+		{"Kernel slab", info["Slab"], fmt.Sprintf("%s reclaimable", humanKB(info["SReclaimable"]))},
+		{"Kernel page tables", info["PageTables"], ""},
+		{"Kernel stacks", info["KernelStack"], ""},
+		{"Kernel vmalloc", info["VmallocUsed"], ""},
+		{"Kernel sockets", sockKB, "TCP, UDP and fragment buffers from net/sockstat"},
+		{"Hugetlb reservations", hugetlb, ""},
+		{"Free", info["MemFree"], ""},
+	}
+	explained := 0
+	for _, l := range lines {
+		explained += l.KB
+	}
+	lines = append(lines, reportLine{"Unexplained", info["MemTotal"] - explained, unexplainedNote})
+	return lines
+}
+
+// readSocketMemory sums the socket buffer memory in net/sockstat under
+// rootpath, in kB. TCP and UDP report pages, fragments report bytes.
+func readSocketMemory(rootpath string) int {
+	total := 0
+	for _, name := range []string{"sockstat", "sockstat6"} {
+		sockstat, err := readFile(filepath.Join(rootpath, "net", name))
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(sockstat), "\n") {
+			fields := strings.Fields(line)
+			for i := 1; i+1 < len(fields); i += 2 {
+				v, err := strconv.Atoi(fields[i+1])
+				if err != nil {
+					continue
+				}
+				switch fields[i] {
+				case "mem":
+					total += v * os.Getpagesize() / 1024
+				case "memory":
+					total += v / 1024
+				}
+			}
+		}
+	}
+	return total
+}
+
+// Formats the reconciliation report for the termui table
+func reportFormat(lines []reportLine, memTotal int) [][]string {
+	tab := [][]string{{"kB", "Size", "Share", "Part", "Notes"},
+		{"--", "----", "-----", "----", "-----"}}
+	for _, l := range lines {
+		tab = append(tab, []string{strconv.Itoa(l.KB), humanKB(l.KB),
+			fmt.Sprintf("%d%%", percentOf(l.KB, memTotal)), l.Label, l.Note})
+	}
+	return append(tab, []string{strconv.Itoa(memTotal), humanKB(memTotal), "100%", "MemTotal", ""})
+}
+
+// Print the reconciliation report
+func printReport(lines []reportLine, memTotal int) {
+	fmt.Printf("%12s  %7s  %5s  %-26s %s\n", "kB", "Size", "Share", "Part", "Notes")
+	for _, l := range lines {
+		line := fmt.Sprintf("%12d  %7s  %4d%%  %-26s %s",
+			l.KB, humanKB(l.KB), percentOf(l.KB, memTotal), l.Label, l.Note)
+		fmt.Println(strings.TrimRight(line, " "))
+	}
+	fmt.Printf("%12d  %7s  %4d%%  %s\n", memTotal, humanKB(memTotal), 100, "MemTotal")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBuildReport(t *testing.T) {
+	info, err := readMeminfo("testdata/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := readSocketMemory("testdata/proc")
+	if want := (12+3)*os.Getpagesize()/1024 + 8; sock != want {
+		t.Errorf("readSocketMemory = %d kB, want %d", sock, want)
+	}
+	split := []*Process{
+		{PSS: 300000, PssAnon: 100000, PssFile: 190000, PssShmem: 10000, PssSplit: true},
+		{PSS: 5000, PssAnon: 5000, PssSplit: true},
+		// Kernel threads and partly read processes have no PSS to split
+		{Kernel: true},
+		{Partial: true, RSS: 4000},
+	}
+	unsplit := []*Process{
+		{PSS: 300000, Source: sourceRollup},
+		{PSS: 5000, Source: sourceSmaps},
+		{Kernel: true},
+	}
+	tests := []struct {
+		name       string
+		procs      []*Process
+		cache, shm int
+		twice      bool
+	}{
+		{"split", split, info["Cached"] + info["Buffers"] - info["Shmem"] - 190000, info["Shmem"] - 10000, false},
+		{"unsplit", unsplit, info["Cached"] + info["Buffers"] - info["Shmem"], info["Shmem"], true},
+	}
+	for _, tt := range tests {
+		lines := buildReport(info, tt.procs, sock)
+		byLabel := make(map[string]reportLine)
+		total := 0
+		for _, l := range lines {
+			byLabel[l.Label] = l
+			total += l.KB
+		}
+		if total != info["MemTotal"] {
+			t.Errorf("%s: report adds up to %d kB, want MemTotal %d", tt.name, total, info["MemTotal"])
+		}
+		if got := byLabel["Userspace processes (PSS)"].KB; got != 305000 {
+			t.Errorf("%s: userspace = %d kB, want 305000", tt.name, got)
+		}
+		if got := byLabel["Page cache"].KB; got != tt.cache {
+			t.Errorf("%s: page cache = %d kB, want %d", tt.name, got, tt.cache)
+		}
+		if got := byLabel["tmpfs / shmem"].KB; got != tt.shm {
+			t.Errorf("%s: shmem = %d kB, want %d", tt.name, got, tt.shm)
+		}
+		if got := byLabel["Kernel sockets"].KB; got != sock {
+			t.Errorf("%s: sockets = %d kB, want %d", tt.name, got, sock)
+		}
+		note := byLabel["Page cache"].Note
+		if twice := strings.Contains(note, "also holds"); twice != tt.twice {
+			t.Errorf("%s: page cache note %q, want it to say whether it holds process pages", tt.name, note)
+		}
+		if tt.twice && !strings.Contains(note, "2 processes") {
+			t.Errorf("%s: page cache note %q should count the 2 processes without a split", tt.name, note)
+		}
+	}
+}
+
+func TestBuildReportHugetlb(t *testing.T) {
+	tests := []struct {
+		info map[string]int
+		want int
+	}{
+		{map[string]int{"Hugetlb": 4096, "HugePages_Total": 1, "Hugepagesize": 2048}, 4096},
+		// Kernels before 4.16 don't report Hugetlb
+		{map[string]int{"HugePages_Total": 3, "Hugepagesize": 2048}, 6144},
+	}
+	for _, tt := range tests {
+		for _, l := range buildReport(tt.info, nil, 0) {
+			if l.Label == "Hugetlb reservations" && l.KB != tt.want {
+				t.Errorf("buildReport(%v) hugetlb = %d kB, want %d", tt.info, l.KB, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Names of the files smaps totals can be read from
+const (
+	sourceRollup = "smaps_rollup"
+	sourceSmaps  = "smaps"
+	// Processes whose smaps couldn't be read
+	sourceStatm = "statm"
+)
+
+// readSmapsTotals sums every kB counter for the process at path. It reads
+// smaps_rollup when the kernel provides it (4.14+) and falls back to parsing
+// the full smaps file otherwise. The returned source names the file used.
+func readSmapsTotals(path string) (map[string]int, string, error) {
+	source := sourceRollup
+	file, err := fsys.Open(filepath.Join(path, sourceRollup))
+	if os.IsNotExist(err) {
+		source = sourceSmaps
+		file, err = fsys.Open(filepath.Join(path, sourceSmaps))
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer file.Close()
+
+	totals, err := sumSmaps(file)
+	if err != nil {
+		return nil, "", err
+	}
+	return totals, source, nil
+}
+
+// Per mapping attributes in kB that mean nothing added up, and which
+// smaps_rollup leaves out
+var unsummedKeys = map[string]bool{"KernelPageSize": true, "MMUPageSize": true}
+
+// sumSmaps adds up each kB counter across all of the mappings in r
+func sumSmaps(r io.Reader) (map[string]int, error) {
+	totals := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if key, kb, ok := parseSmapLine(scanner.Text()); ok && !unsummedKeys[key] {
+			totals[key] += kb
+		}
+	}
+	return totals, scanner.Err()
+}
+
+// parseSmapLine splits a "Key:   123 kB" smaps line into its key and value.
+// Mapping headers and lines without a kB value are rejected.
+func parseSmapLine(line string) (string, int, bool) {
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[2] != "kB" || !strings.HasSuffix(fields[0], ":") {
+		return "", 0, false
+	}
+	kb, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", 0, false
+	}
+	return strings.TrimSuffix(fields[0], ":"), kb, true
+}
+
+// Mapping is a single VMA from /proc/<pid>/smaps
+type Mapping struct {
+	Start, End uint64
+	Perms      string
+	Offset     uint64
+	Dev        string
+	Inode      uint64
+	// Path is the backing file or a pseudo-name like [heap] or [anon:foo].
+	// It's empty for plain anonymous mappings.
+	Path string
+	// Counters holds every kB counter smaps reported for the mapping
+	Counters map[string]int
+}
+
+// USS is the memory private to the mapping
+func (m *Mapping) USS() int {
+	return m.Counters["Private_Clean"] + m.Counters["Private_Dirty"]
+}
+
+// Name returns the path, or [anon] for an unnamed anonymous mapping
+func (m *Mapping) Name() string {
+	if m.Path == "" {
+		return "[anon]"
+	}
+	return m.Path
+}
+
+// readMappings reads every mapping of the process at path from its smaps
+func readMappings(path string) ([]*Mapping, error) {
+	file, err := fsys.Open(filepath.Join(path, sourceSmaps))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseSmaps(file)
+}
+
+// parseSmaps parses the mapping headers and counters of an smaps file
+func parseSmaps(r io.Reader) ([]*Mapping, error) {
+	var maps []*Mapping
+	var cur *Mapping
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m, ok := parseMappingHeader(line); ok {
+			cur = m
+			maps = append(maps, cur)
+			continue
+		}
+		if key, kb, ok := parseSmapLine(line); ok && cur != nil {
+			cur.Counters[key] = kb
+		}
+	}
+	return maps, scanner.Err()
+}
+
+// parseMappingHeader parses a line like
+// "7f2c1e400000-7f2c1e5a2000 r-xp 00000000 08:01 1234  /usr/lib/libc.so.6"
+func parseMappingHeader(line string) (*Mapping, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || strings.HasSuffix(fields[0], ":") {
+		return nil, false
+	}
+	bounds := strings.SplitN(fields[0], "-", 2)
+	if len(bounds) != 2 {
+		return nil, false
+	}
+	start, err := strconv.ParseUint(bounds[0], 16, 64)
+	if err != nil {
+		return nil, false
+	}
+	end, err := strconv.ParseUint(bounds[1], 16, 64)
+	if err != nil {
+		return nil, false
+	}
+	offset, err := strconv.ParseUint(fields[2], 16, 64)
+	if err != nil {
+		return nil, false
+	}
+	inode, err := strconv.ParseUint(fields[4], 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return &Mapping{
+		Start:    start,
+		End:      end,
+		Perms:    fields[1],
+		Offset:   offset,
+		Dev:      fields[3],
+		Inode:    inode,
+		Path:     strings.Join(fields[5:], " "),
+		Counters: make(map[string]int),
+	}, true
+}
+
+// sortMappings orders mappings by the given sort key. Name sorts ascending
+// by path, all else sorts descending.
+func sortMappings(maps []*Mapping, key string) {
+	switch key {
+	case "name":
+		sort.SliceStable(maps, func(i, j int) bool { return maps[i].Name() < maps[j].Name() })
+	case "rss":
+		sort.SliceStable(maps, func(i, j int) bool { return maps[i].Counters["Rss"] > maps[j].Counters["Rss"] })
+	case "pss":
+		sort.SliceStable(maps, func(i, j int) bool { return maps[i].Counters["Pss"] > maps[j].Counters["Pss"] })
+	case "uss":
+		sort.SliceStable(maps, func(i, j int) bool { return maps[i].USS() > maps[j].USS() })
+	case "swap":
+		sort.SliceStable(maps, func(i, j int) bool { return maps[i].Counters["SwapPss"] > maps[j].Counters["SwapPss"] })
+	}
+}
+
+// Formats the mappings of one process for the termui table
+func mapsFormat(maps []*Mapping) [][]string {
+	tab := [][]string{{"Address", "Perm", "Offset", "Inode", "SwapPSS", "USS", "PSS", "RSS", "Mapping"},
+		{"-------", "----", "------", "-----", "-------", "---", "---", "---", "-------"}}
+	for _, m := range maps {
+		tab = append(tab, []string{fmt.Sprintf("%x-%x", m.Start, m.End), m.Perms,
+			fmt.Sprintf("%08x", m.Offset), strconv.FormatUint(m.Inode, 10),
+			strconv.Itoa(m.Counters["SwapPss"]), strconv.Itoa(m.USS()),
+			strconv.Itoa(m.Counters["Pss"]), strconv.Itoa(m.Counters["Rss"]), m.Name()})
+	}
+	return tab
+}
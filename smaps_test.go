@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseSmapLine(t *testing.T) {
+	tests := []struct {
+		line string
+		key  string
+		kb   int
+		ok   bool
+	}{
+		{"Rss:                  24 kB", "Rss", 24, true},
+		{"Pss_Dirty:             0 kB", "Pss_Dirty", 0, true},
+		{"Private_Hugetlb:       0 kB", "Private_Hugetlb", 0, true},
+		{"THPeligible:           0", "", 0, false},
+		{"VmFlags: rd ex mr mw me", "", 0, false},
+		{"562034b42000-562034b48000 r-xp 00002000 fe:00 681885                     /usr/bin/head", "", 0, false},
+		{"Rss:                  xx kB", "", 0, false},
+		{"", "", 0, false},
+	}
+	for _, tt := range tests {
+		key, kb, ok := parseSmapLine(tt.line)
+		if key != tt.key || kb != tt.kb || ok != tt.ok {
+			t.Errorf("parseSmapLine(%q) = %q, %d, %v; want %q, %d, %v",
+				tt.line, key, kb, ok, tt.key, tt.kb, tt.ok)
+		}
+	}
+}
+
+func TestParseMappingHeader(t *testing.T) {
+	tests := []struct {
+		line string
+		want *Mapping
+	}{
+		{"562034b42000-562034b48000 r-xp 00002000 fe:00 681885                     /usr/bin/head",
+			&Mapping{Start: 0x562034b42000, End: 0x562034b48000, Perms: "r-xp", Offset: 0x2000,
+				Dev: "fe:00", Inode: 681885, Path: "/usr/bin/head"}},
+		{"56173a27e000-56173a291000 rw-p 00000000 00:00 0 ",
+			&Mapping{Start: 0x56173a27e000, End: 0x56173a291000, Perms: "rw-p",
+				Dev: "00:00"}},
+		{"7ffd02db6000-7ffd02dd7000 rw-p 00000000 00:00 0                          [stack]",
+			&Mapping{Start: 0x7ffd02db6000, End: 0x7ffd02dd7000, Perms: "rw-p",
+				Dev: "00:00", Path: "[stack]"}},
+		{"7f6b3c3ff000-7f6b3c400000 rw-s 00000000 00:01 2050                       /memfd:wayland-shm (deleted)",
+			&Mapping{Start: 0x7f6b3c3ff000, End: 0x7f6b3c400000, Perms: "rw-s",
+				Dev: "00:01", Inode: 2050, Path: "/memfd:wayland-shm (deleted)"}},
+		{"Rss:                  24 kB", nil},
+		{"VmFlags: rd ex mr mw me", nil},
+		{"zzzz-562034b48000 r-xp 00002000 fe:00 681885 /usr/bin/head", nil},
+	}
+	for _, tt := range tests {
+		m, ok := parseMappingHeader(tt.line)
+		if tt.want == nil {
+			if ok {
+				t.Errorf("parseMappingHeader(%q) accepted a non-header line", tt.line)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("parseMappingHeader(%q) rejected a header", tt.line)
+			continue
+		}
+		m.Counters = nil
+		if !reflect.DeepEqual(m, tt.want) {
+			t.Errorf("parseMappingHeader(%q) = %+v, want %+v", tt.line, m, tt.want)
+		}
+	}
+}
+
+func TestParseSmaps(t *testing.T) {
+	file, err := os.Open("testdata/smaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	maps, err := parseSmaps(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name     string
+		rss, uss int
+		counters int
+	}{
+		{"/usr/bin/mawk", 16, 16, 22},
+		{"[anon]", 16, 16, 22},
+		{"[heap]", 32, 32, 22},
+		{"[stack]", 16, 16, 22},
+	}
+	if len(maps) != len(want) {
+		t.Fatalf("parsed %d mappings, want %d", len(maps), len(want))
+	}
+	for i, w := range want {
+		m := maps[i]
+		if m.Name() != w.name || m.Counters["Rss"] != w.rss || m.USS() != w.uss || len(m.Counters) != w.counters {
+			t.Errorf("mapping %d = %s with Rss %d, USS %d and %d counters; want %s, %d, %d, %d",
+				i, m.Name(), m.Counters["Rss"], m.USS(), len(m.Counters), w.name, w.rss, w.uss, w.counters)
+		}
+	}
+}
+
+func TestSumSmaps(t *testing.T) {
+	file, err := os.Open("testdata/smaps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	totals, err := sumSmaps(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totals["Size"] != 356 || totals["Rss"] != 80 {
+		t.Errorf("Size %d and Rss %d kB, want 356 and 80", totals["Size"], totals["Rss"])
+	}
+	// Page sizes describe each mapping rather than amounts of memory
+	for key := range unsummedKeys {
+		if v, ok := totals[key]; ok {
+			t.Errorf("totals include %s %d kB", key, v)
+		}
+	}
+}
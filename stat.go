@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PF_KTHREAD in the stat flags field marks a kernel thread
+const pfKthread = 0x00200000
+
+// procStat holds the fields uptop uses from /proc/<pid>/stat
+type procStat struct {
+	Name  string
+	State string
+	PPID  int
+	Flags uint64
+	// Minor and major page faults, and user and system CPU time in clock ticks
+	MinFlt, MajFlt uint64
+	UTime, STime   uint64
+	// Start is when the process started, in clock ticks after boot
+	Start uint64
+}
+
+// readStat parses the stat file of the process at path
+func readStat(path string) (*procStat, error) {
+	statp, err := readFile(filepath.Join(path, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	return parseStat(string(statp))
+}
+
+// parseStat parses the contents of a stat file. The name is everything
+// between the first "(" and the last ")", since it may contain either.
+func parseStat(stat string) (*procStat, error) {
+	open := strings.IndexByte(stat, '(')
+	shut := strings.LastIndexByte(stat, ')')
+	if open < 0 || shut < open {
+		return nil, fmt.Errorf("malformed stat %q", stat)
+	}
+	// Fields after the name, starting with field 3 (state)
+	fields := strings.Fields(stat[shut+1:])
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("short stat %q", stat)
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, err
+	}
+	// flags, minflt, majflt, utime, stime and starttime are fields 9, 10,
+	// 12, 14, 15 and 22
+	var counters [6]uint64
+	for i, n := range []int{9, 10, 12, 14, 15, 22} {
+		counters[i], err = strconv.ParseUint(fields[n-3], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &procStat{
+		Name:   stat[open+1 : shut],
+		State:  fields[0],
+		PPID:   ppid,
+		Flags:  counters[0],
+		MinFlt: counters[1],
+		MajFlt: counters[2],
+		UTime:  counters[3],
+		STime:  counters[4],
+		Start:  counters[5],
+	}, nil
+}
+
+// isKernelThread reports whether the stat belongs to a kernel thread
+func (s *procStat) isKernelThread() bool {
+	return s.Flags&pfKthread != 0
+}
+
+// procStatm holds the sizes uptop uses from /proc/<pid>/statm, in pages
+type procStatm struct {
+	Resident, Shared, Text int
+}
+
+// readStatm reads the statm file of the process at path. It's much cheaper
+// to read than smaps, and readable for every process.
+func readStatm(path string) (procStatm, error) {
+	statm, err := readFile(filepath.Join(path, "statm"))
+	if err != nil {
+		return procStatm{}, err
+	}
+	// size resident shared text lib data dt
+	fields := strings.Fields(string(statm))
+	if len(fields) < 4 {
+		return procStatm{}, fmt.Errorf("short statm %q", statm)
+	}
+	var pages [3]int
+	for i := range pages {
+		pages[i], err = strconv.Atoi(fields[i+1])
+		if err != nil {
+			return procStatm{}, err
+		}
+	}
+	return procStatm{Resident: pages[0], Shared: pages[1], Text: pages[2]}, nil
+}
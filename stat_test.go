@@ -0,0 +1,50 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStat(t *testing.T) {
+	tests := []struct {
+		stat string
+		want *procStat
+	}{
+		{"27933 (cat) R 27928 27933 27928 0 -1 4194304 81 0 0 0 0 0 0 0 20 0 1 0 253105 2703360 313 18446744073709551615 94503860064256 94503860084137 140736985332864 0 0 0 0 0 0 0 0 0 17 0 0 0 0 0 0 94503860100144 94503860101760 94504048095232 140736985335111 140736985335131 140736985335131 140736985337835 0\n",
+			&procStat{Name: "cat", State: "R", PPID: 27928, Flags: 4194304, MinFlt: 81, Start: 253105}},
+		{"2 (kthreadd) S 0 0 0 0 -1 2129984 0 0 0 0 0 0 0 0 20 0 1 0 6 0 0 18446744073709551615 0 0 0 0 0 0 0 2147483647 0 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0\n",
+			&procStat{Name: "kthreadd", State: "S", Flags: 2129984, Start: 6}},
+		// The name is whatever the process called itself, parentheses and all
+		{"27941 (a) b (c) S 27940 27940 27934 0 -1 4194304 125 0 0 0 0 0 0 0 20 0 1 0 253358 2560000 346 18446744073709551615 94894002413568 94894002431497 140737261997728 0 0 0 0 6 0 1 0 0 17 0 0 0 0 0 0 94894002445584 94894002446848 94895048269824 140737261999435 140737261999451 140737261999451 140737262002155 0\n",
+			&procStat{Name: "a) b (c", State: "S", PPID: 27940, Flags: 4194304, MinFlt: 125, Start: 253358}},
+		{"31 () Z 1 31 31 0 -1 4227084 392 0 3 0 12 7 0 0 20 0 1 0 1450 0 0 18446744073709551615 0 0 0 0 0 0 0 0 0 0 0 0 17 2 0 0 0 0 0 0 0 0 0 0 0 0 0\n",
+			&procStat{Name: "", State: "Z", PPID: 1, Flags: 4227084, MinFlt: 392, MajFlt: 3, UTime: 12, STime: 7, Start: 1450}},
+		{"27933 cat R 27928", nil},
+		{"27933 (cat) R 27928 27933 27928 0 -1", nil},
+		{"27933 (cat) R x 27933 27928 0 -1 4194304 81 0 0 0 0 0 0 0 20 0 1 0 253105 2703360", nil},
+	}
+	for _, tt := range tests {
+		got, err := parseStat(tt.stat)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("parseStat(%q) = %+v, want an error", tt.stat, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseStat(%q): %v", tt.stat, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseStat(%q) = %+v, want %+v", tt.stat, got, tt.want)
+		}
+	}
+}
+
+func TestIsKernelThread(t *testing.T) {
+	for flags, want := range map[uint64]bool{2129984: true, 4194304: false, 4227084: false} {
+		if got := (&procStat{Flags: flags}).isKernelThread(); got != want {
+			t.Errorf("isKernelThread with flags %#x = %v, want %v", flags, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UIDs are a process's user IDs from the Uid line of its status
+type UIDs struct {
+	Real, Effective, Saved uint32
+}
+
+// Which of the UIDs the User column shows: real, effective or saved
+var userUID = "effective"
+
+// Titles of the User column for each choice of UID, after ps
+var userTitles = map[string]string{
+	"real":      "RUser",
+	"effective": "User",
+	"saved":     "SUser",
+}
+
+// readStatus returns the fields of the status file of the process at path,
+// keyed by name with surrounding whitespace trimmed from the values
+func readStatus(path string) (map[string]string, error) {
+	status, err := readFile(filepath.Join(path, "status"))
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string)
+	for _, line := range strings.Split(string(status), "\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		fields[line[:i]] = strings.TrimSpace(line[i+1:])
+	}
+	return fields, nil
+}
+
+// parseUIDs parses the value of a Uid line: the real, effective, saved set
+// and filesystem UIDs
+func parseUIDs(value string) (UIDs, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return UIDs{}, fmt.Errorf("short Uid line %q", value)
+	}
+	var ids [3]uint32
+	for i := range ids {
+		id, err := strconv.ParseUint(fields[i], 10, 32)
+		if err != nil {
+			return UIDs{}, err
+		}
+		ids[i] = uint32(id)
+	}
+	return UIDs{Real: ids[0], Effective: ids[1], Saved: ids[2]}, nil
+}
+
+// Pick returns the UID the User column shows
+func (u UIDs) Pick(which string) uint32 {
+	switch which {
+	case "real":
+		return u.Real
+	case "saved":
+		return u.Saved
+	}
+	return u.Effective
+}
+
+// userTitle is the title of the User column
+func userTitle() string {
+	return userTitles[userUID]
+}
+
+// statusKB parses a status value like "1234 kB", or returns 0
+func statusKB(value string) int {
+	kb, _ := strconv.Atoi(strings.TrimSuffix(value, " kB"))
+	return kb
+}
@@ -0,0 +1,63 @@
+package main
+
+import "testing"
+
+func TestParseUIDs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  UIDs
+		ok    bool
+	}{
+		{"0\t0\t0\t0", UIDs{}, true},
+		{"1000\t1000\t1000\t1000", UIDs{Real: 1000, Effective: 1000, Saved: 1000}, true},
+		// A setuid root program run by a user, and a daemon that dropped root
+		// but kept it as the saved UID
+		{"1000\t0\t0\t0", UIDs{Real: 1000, Effective: 0, Saved: 0}, true},
+		{"33\t33\t0\t33", UIDs{Real: 33, Effective: 33, Saved: 0}, true},
+		// The overflow UID and the largest UID there is
+		{"65534\t65534\t65534\t65534", UIDs{Real: 65534, Effective: 65534, Saved: 65534}, true},
+		{"4294967294 4294967294 4294967294 4294967294", UIDs{Real: 4294967294, Effective: 4294967294, Saved: 4294967294}, true},
+		{"1000\t1000", UIDs{}, false},
+		{"", UIDs{}, false},
+		{"1000\tx\t1000\t1000", UIDs{}, false},
+		{"4294967296\t0\t0\t0", UIDs{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseUIDs(tt.value)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseUIDs(%q) = %+v, %v; want %+v, ok %v", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestPickUID(t *testing.T) {
+	u := UIDs{Real: 1000, Effective: 0, Saved: 33}
+	for which, want := range map[string]uint32{"real": 1000, "effective": 0, "saved": 33, "": 0} {
+		if got := u.Pick(which); got != want {
+			t.Errorf("Pick(%q) = %d, want %d", which, got, want)
+		}
+	}
+}
+
+func TestReadStatus(t *testing.T) {
+	status, err := readStatus("testdata/proc/27933")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"Name":    "cat",
+		"Uid":     "0\t0\t0\t0",
+		"VmRSS":   "1400 kB",
+		"RssAnon": "100 kB",
+		"VmSwap":  "0 kB",
+		"Threads": "1",
+	}
+	for key, value := range want {
+		if status[key] != value {
+			t.Errorf("status[%q] = %q, want %q", key, status[key], value)
+		}
+	}
+	if kb := statusKB(status["VmRSS"]); kb != 1400 {
+		t.Errorf("statusKB(%q) = %d, want 1400", status["VmRSS"], kb)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import "strings"
+
+// parseSystemd works out the systemd slice, unit and login session of a
+// process from its cgroup paths, e.g. system.slice/nginx.service or
+// user.slice/user-1000.slice/session-3.scope. Units run by a user's own
+// systemd, like user.slice/user-1000.slice/user@1000.service/app.slice/foo.service,
+// are the innermost unit, with the user@ service returned as the manager.
+// The slice is the one the outermost unit sits in. Processes outside any
+// unit, like kernel threads, get empty strings.
+func parseSystemd(paths []string) (slice, unit, session, manager string) {
+	for _, path := range paths {
+		slice, unit, session, manager = "", "", "", ""
+		for _, seg := range strings.Split(path, "/") {
+			if strings.HasSuffix(seg, ".slice") {
+				if unit == "" {
+					slice = seg
+				}
+				continue
+			}
+			if !strings.HasSuffix(seg, ".service") && !strings.HasSuffix(seg, ".scope") {
+				continue
+			}
+			if unit == "" && strings.HasPrefix(seg, "user@") {
+				manager = seg
+			}
+			unit = seg
+			if strings.HasPrefix(seg, "session-") && strings.HasSuffix(seg, ".scope") {
+				session = strings.TrimSuffix(strings.TrimPrefix(seg, "session-"), ".scope")
+			}
+		}
+		if unit != "" {
+			if unit == manager {
+				manager = ""
+			}
+			return slice, unit, session, manager
+		}
+	}
+	return "", "", "", ""
+}
+
+// unitKey groups by systemd unit, with processes outside any unit together.
+// Units of a user's systemd are prefixed with its user@ service, so that
+// e.g. its init.scope isn't mistaken for the system's.
+func unitKey(p *Process) string {
+	if p.Unit == "" {
+		return "(none)"
+	}
+	if p.Manager != "" {
+		return p.Manager + "/" + p.Unit
+	}
+	return p.Unit
+}
+
+// sessionKey groups by login session, with processes outside any together
+func sessionKey(p *Process) string {
+	if p.Session == "" {
+		return "(none)"
+	}
+	return "session-" + p.Session
+}
+
+// sliceKey groups by systemd slice
+func sliceKey(p *Process) string {
+	if p.Slice == "" {
+		return "(none)"
+	}
+	return p.Slice
+}
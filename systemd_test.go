@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestParseSystemd(t *testing.T) {
+	tests := []struct {
+		paths                         []string
+		slice, unit, session, manager string
+	}{
+		{[]string{"/system.slice/nginx.service"}, "system.slice", "nginx.service", "", ""},
+		{[]string{"/init.scope"}, "", "init.scope", "", ""},
+		{[]string{"/user.slice/user-1000.slice/session-3.scope"}, "user-1000.slice", "session-3.scope", "3", ""},
+		// The user's systemd itself, and the units it runs
+		{[]string{"/user.slice/user-1000.slice/user@1000.service/init.scope"},
+			"user-1000.slice", "init.scope", "", "user@1000.service"},
+		{[]string{"/user.slice/user-1000.slice/user@1000.service/app.slice/app-org.gnome.Terminal.slice/vte-spawn-8f1b.scope"},
+			"user-1000.slice", "vte-spawn-8f1b.scope", "", "user@1000.service"},
+		{[]string{"/user.slice/user-1000.slice/user@1000.service/session.slice/pipewire.service"},
+			"user-1000.slice", "pipewire.service", "", "user@1000.service"},
+		{[]string{"/user.slice/user-1000.slice/user@1000.service"},
+			"user-1000.slice", "user@1000.service", "", ""},
+		// Containers, where anything below the scope isn't a unit
+		{[]string{"/system.slice/docker-4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b.scope"},
+			"system.slice", "docker-4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b.scope", "", ""},
+		{[]string{"/machine.slice/libpod-4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b.scope/container"},
+			"machine.slice", "libpod-4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b.scope", "", ""},
+		// cgroup v1 paths, where only some hierarchies name the unit
+		{[]string{"/", "/system.slice/cron.service"}, "system.slice", "cron.service", "", ""},
+		// Kernel threads and cgroupfs containers
+		{[]string{"/"}, "", "", "", ""},
+		{[]string{"/docker/4f3c2a1b0e9d8c7b6a5f4e3d2c1b0a9f8e7d6c5b4a3f2e1d0c9b8a7f6e5d4c3b"}, "", "", "", ""},
+		{nil, "", "", "", ""},
+	}
+	for _, tt := range tests {
+		slice, unit, session, manager := parseSystemd(tt.paths)
+		if slice != tt.slice || unit != tt.unit || session != tt.session || manager != tt.manager {
+			t.Errorf("parseSystemd(%q) = %q, %q, %q, %q; want %q, %q, %q, %q", tt.paths,
+				slice, unit, session, manager, tt.slice, tt.unit, tt.session, tt.manager)
+		}
+	}
+}
+
+func TestUnitKey(t *testing.T) {
+	tests := []struct {
+		p    Process
+		want string
+	}{
+		{Process{}, "(none)"},
+		{Process{Unit: "init.scope"}, "init.scope"},
+		{Process{Unit: "init.scope", Manager: "user@1000.service"}, "user@1000.service/init.scope"},
+	}
+	for _, tt := range tests {
+		if got := unitKey(&tt.p); got != tt.want {
+			t.Errorf("unitKey(%q, %q) = %q, want %q", tt.p.Unit, tt.p.Manager, got, tt.want)
+		}
+	}
+}
+
+func TestSessionKey(t *testing.T) {
+	for session, want := range map[string]string{"": "(none)", "3": "session-3", "c2": "session-c2"} {
+		if got := sessionKey(&Process{Session: session}); got != want {
+			t.Errorf("sessionKey(%q) = %q, want %q", session, got, want)
+		}
+	}
+}
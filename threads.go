@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Thread is one task of a process, from /proc/<pid>/task/<tid>
+type Thread struct {
+	TID         int
+	Name, State string
+	// Stack is the mapping holding the thread's stack, or nil if it
+	// couldn't be found
+	Stack *Mapping
+}
+
+// StackRSS is the resident size of the thread's stack mapping
+func (t *Thread) StackRSS() int {
+	if t.Stack == nil {
+		return 0
+	}
+	return t.Stack.Counters["Rss"]
+}
+
+// GetThreads lists the threads of the process at path along with the
+// mapping each one's stack lives in
+func GetThreads(path string) ([]*Thread, error) {
+	pid, err := strconv.Atoi(filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := fsys.ReadDir(filepath.Join(path, "task"))
+	if err != nil {
+		return nil, err
+	}
+	// Without access to smaps the threads are still listed, just without stacks
+	maps, _ := readMappings(path)
+	threads := []*Thread{}
+	for _, f := range dirs {
+		tid, err := strconv.Atoi(f.Name())
+		if err != nil {
+			continue
+		}
+		taskpath := filepath.Join(path, "task", f.Name())
+		stat, err := readStat(taskpath)
+		if err != nil {
+			continue
+		}
+		t := &Thread{TID: tid, Name: stat.Name, State: stat.State}
+		t.Stack = findStack(maps, pid, tid, readStackPointer(taskpath))
+		threads = append(threads, t)
+	}
+	sortThreads(threads, sortKey)
+	return threads, nil
+}
+
+// readStackPointer returns the user stack pointer of a thread blocked in a
+// syscall, the second to last field of its syscall file. It's 0 when the
+// thread is running or the file can't be read, which needs ptrace access.
+func readStackPointer(taskpath string) uint64 {
+	syscall, err := readFile(filepath.Join(taskpath, "syscall"))
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(syscall))
+	if len(fields) < 3 {
+		return 0
+	}
+	sp, err := strconv.ParseUint(strings.TrimPrefix(fields[len(fields)-2], "0x"), 16, 64)
+	if err != nil {
+		return 0
+	}
+	return sp
+}
+
+// findStack picks the mapping holding a thread's stack. Kernels before 4.5
+// name thread stacks [stack:<tid>]; otherwise the mapping containing the
+// stack pointer is used, falling back to [stack] for the main thread.
+func findStack(maps []*Mapping, pid, tid int, sp uint64) *Mapping {
+	named := fmt.Sprintf("[stack:%d]", tid)
+	for _, m := range maps {
+		if m.Path == named {
+			return m
+		}
+	}
+	if sp != 0 {
+		for _, m := range maps {
+			if m.Start <= sp && sp < m.End {
+				return m
+			}
+		}
+	}
+	if tid == pid {
+		for _, m := range maps {
+			if m.Path == "[stack]" {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+// stacksRSS sums the resident size of the distinct stack mappings, since
+// some runtimes carve several thread stacks out of one mapping
+func stacksRSS(threads []*Thread) int {
+	seen := make(map[uint64]bool)
+	total := 0
+	for _, t := range threads {
+		if t.Stack != nil && !seen[t.Stack.Start] {
+			seen[t.Stack.Start] = true
+			total += t.StackRSS()
+		}
+	}
+	return total
+}
+
+// sortThreads orders threads by the given sort key. Name sorts ascending,
+// all else sorts by stack RSS descending.
+func sortThreads(threads []*Thread, key string) {
+	sort.SliceStable(threads, func(i, j int) bool { return threads[i].TID < threads[j].TID })
+	switch key {
+	case "name":
+		sort.SliceStable(threads, func(i, j int) bool { return threads[i].Name < threads[j].Name })
+	default:
+		sort.SliceStable(threads, func(i, j int) bool { return threads[i].StackRSS() > threads[j].StackRSS() })
+	}
+}
+
+// Formats the threads of one process for the termui table
+func threadsFormat(threads []*Thread) [][]string {
+	tab := [][]string{{"TID", "Name", "S", "StkSize", "StkRSS", "Stack"},
+		{"---", "----", "-", "-------", "------", "-----"}}
+	for _, t := range threads {
+		size, rss, stack := "-", "-", "-"
+		if t.Stack != nil {
+			size = strconv.Itoa(t.Stack.Counters["Size"])
+			rss = strconv.Itoa(t.StackRSS())
+			stack = fmt.Sprintf("%x-%x %s", t.Stack.Start, t.Stack.End, t.Stack.Name())
+		}
+		tab = append(tab, []string{strconv.Itoa(t.TID), t.Name, t.State, size, rss, stack})
+	}
+	return tab
+}
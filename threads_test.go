@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestReadStackPointer(t *testing.T) {
+	tests := []struct {
+		task string
+		want uint64
+	}{
+		// Blocked in a syscall, the stack pointer is second to last
+		{"27933", 0x7fff55dce368},
+		// Running, so there's nothing to read
+		{"27935", 0},
+		// Blocked but not in a syscall
+		{"27936", 0x7f3a1bffec88},
+		// Without ptrace access the file can't be read
+		{"27999", 0},
+	}
+	for _, tt := range tests {
+		if got := readStackPointer("testdata/proc/27933/task/" + tt.task); got != tt.want {
+			t.Errorf("readStackPointer(%s) = %#x, want %#x", tt.task, got, tt.want)
+		}
+	}
+}
+
+func TestFindStack(t *testing.T) {
+	stack := &Mapping{Start: 0x7fff55db0000, End: 0x7fff55dd1000, Path: "[stack]"}
+	heap := &Mapping{Start: 0x55d4c8a1e000, End: 0x55d4c8a3f000, Path: "[heap]"}
+	// glibc thread stacks are plain anonymous mappings
+	thread := &Mapping{Start: 0x7f3a1b7ff000, End: 0x7f3a1bfff000}
+	// Kernels before 4.5 name them
+	named := &Mapping{Start: 0x7f3a1c000000, End: 0x7f3a1c800000, Path: "[stack:27937]"}
+	modern := []*Mapping{heap, thread, stack}
+	old := []*Mapping{heap, named, stack}
+	tests := []struct {
+		name string
+		maps []*Mapping
+		tid  int
+		sp   uint64
+		want *Mapping
+	}{
+		{"main thread by sp", modern, 27933, 0x7fff55dce368, stack},
+		{"main thread without sp", modern, 27933, 0, stack},
+		{"thread by sp", modern, 27936, 0x7f3a1bffec88, thread},
+		{"thread without sp", modern, 27936, 0, nil},
+		{"sp outside any mapping", modern, 27936, 0x1000, nil},
+		{"named thread stack", old, 27937, 0, named},
+		{"named stack wins over sp", old, 27937, 0x7fff55dce368, named},
+		{"no mappings", nil, 27933, 0x7fff55dce368, nil},
+	}
+	for _, tt := range tests {
+		if got := findStack(tt.maps, 27933, tt.tid, tt.sp); got != tt.want {
+			t.Errorf("%s: findStack = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStacksRSS(t *testing.T) {
+	// Go and other runtimes carve several stacks out of one mapping
+	shared := &Mapping{Start: 0xc000000000, Counters: map[string]int{"Rss": 64}}
+	own := &Mapping{Start: 0x7fff55db0000, Counters: map[string]int{"Rss": 16}}
+	threads := []*Thread{{TID: 1, Stack: own}, {TID: 2, Stack: shared}, {TID: 3, Stack: shared}, {TID: 4}}
+	if got := stacksRSS(threads); got != 80 {
+		t.Errorf("stacksRSS = %d, want 80", got)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// treeNode is a process and its children in the process tree
+type treeNode struct {
+	*Process
+	Children []*treeNode
+	// Sums over the whole subtree, the process itself included
+	TotalRSS, TotalPSS, TotalUSS, TotalSwap int
+	// PartialTotals is set when the subtree holds processes whose smaps
+	// couldn't be read, which the PSS, USS and SwapPSS totals leave out
+	PartialTotals bool
+}
+
+// treeRow is a node as it's listed, with its depth in the tree
+type treeRow struct {
+	*treeNode
+	Depth int
+}
+
+// buildTree links each process to its parent and sums the subtrees.
+// Processes whose parent isn't listed become roots.
+func buildTree(procs []*Process) []*treeNode {
+	nodes := make(map[int]*treeNode, len(procs))
+	for _, p := range procs {
+		nodes[p.PID] = &treeNode{Process: p}
+	}
+	var roots []*treeNode
+	for _, p := range procs {
+		n := nodes[p.PID]
+		if parent, ok := nodes[p.PPID]; ok && p.PPID != p.PID {
+			parent.Children = append(parent.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	for _, n := range roots {
+		n.sum()
+	}
+	sortTree(roots, sortKey)
+	return roots
+}
+
+// sum fills in the subtree totals of n and all of its descendants
+func (n *treeNode) sum() {
+	n.TotalRSS, n.TotalPSS, n.TotalUSS, n.TotalSwap = n.RSS, n.PSS, n.USS, n.Swap
+	n.PartialTotals = n.Partial
+	for _, c := range n.Children {
+		c.sum()
+		n.TotalRSS += c.TotalRSS
+		n.TotalPSS += c.TotalPSS
+		n.TotalUSS += c.TotalUSS
+		n.TotalSwap += c.TotalSwap
+		n.PartialTotals = n.PartialTotals || c.PartialTotals
+	}
+}
+
+// sortTree orders siblings at every level by PID, then by the subtree
+// totals of the given sort key. Name sorts ascending, all else descending.
+func sortTree(nodes []*treeNode, key string) {
+	sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].PID < nodes[j].PID })
+	switch key {
+	case "name":
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	case "rss":
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].TotalRSS > nodes[j].TotalRSS })
+	case "pss":
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].TotalPSS > nodes[j].TotalPSS })
+	case "uss":
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].TotalUSS > nodes[j].TotalUSS })
+	case "swap":
+		sort.SliceStable(nodes, func(i, j int) bool { return nodes[i].TotalSwap > nodes[j].TotalSwap })
+	}
+	for _, n := range nodes {
+		sortTree(n.Children, key)
+	}
+}
+
+// flattenTree lists the nodes depth first, leaving out the descendants of
+// collapsed nodes, which are keyed by PID
+func flattenTree(nodes []*treeNode, collapsed map[int]bool) []treeRow {
+	var rows []treeRow
+	var walk func(nodes []*treeNode, depth int)
+	walk = func(nodes []*treeNode, depth int) {
+		for _, n := range nodes {
+			rows = append(rows, treeRow{n, depth})
+			if !collapsed[n.PID] {
+				walk(n.Children, depth+1)
+			}
+		}
+	}
+	walk(nodes, 0)
+	return rows
+}
+
+// Formats the process tree for the termui table. The command is indented by
+// depth and marked with - for expanded and + for collapsed parents. Totals
+// leaving out processes without smaps are marked with *.
+func treeFormat(rows []treeRow, collapsed map[int]bool) [][]string {
+	tab := [][]string{{"PID", "Name", userTitle(), "SwapPSS", "USS", "PSS", "RSS",
+		"TotSwap", "TotUSS", "TotPSS", "TotRSS", "Command"},
+		{"---", "----", "----", "-------", "---", "---", "---",
+			"-------", "------", "------", "------", "-------"}}
+	for _, r := range rows {
+		marker := "  "
+		if len(r.Children) > 0 {
+			marker = "- "
+			if collapsed[r.PID] {
+				marker = "+ "
+			}
+		}
+		p := r.Process
+		tab = append(tab, []string{strconv.Itoa(r.PID), r.Name, r.User, partialCell(p, r.Swap),
+			partialCell(p, r.USS), partialCell(p, r.PSS), strconv.Itoa(r.RSS),
+			totalCell(r.TotalSwap, r.PartialTotals), totalCell(r.TotalUSS, r.PartialTotals),
+			totalCell(r.TotalPSS, r.PartialTotals), strconv.Itoa(r.TotalRSS),
+			strings.Repeat("  ", r.Depth) + marker + r.Command})
+	}
+	return tab
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTreeTotals(t *testing.T) {
+	defer func(saved string) { sortKey = saved }(sortKey)
+	sortKey = "pss"
+	// A forking server whose worker running as another user couldn't be
+	// read, next to a process on its own
+	procs := []*Process{
+		{PID: 100, PPID: 1, Name: "postgres", RSS: 50, PSS: 30, USS: 20, Swap: 1},
+		{PID: 101, PPID: 100, Name: "postgres", RSS: 40, PSS: 10, USS: 5, Swap: 2},
+		{PID: 102, PPID: 100, Name: "postgres", RSS: 45, Partial: true},
+		{PID: 200, PPID: 1, Name: "sshd", RSS: 8, PSS: 6, USS: 4},
+	}
+	rows := flattenTree(buildTree(procs), map[int]bool{})
+	tab := treeFormat(rows, map[int]bool{})
+	want := [][]string{
+		// PID, SwapPSS, USS, PSS, RSS, TotSwap, TotUSS, TotPSS, TotRSS
+		{"100", "1", "20", "30", "50", "3*", "25*", "40*", "135"},
+		{"101", "2", "5", "10", "40", "2", "5", "10", "40"},
+		{"102", "-", "-", "-", "45", "0*", "0*", "0*", "45"},
+		{"200", "0", "4", "6", "8", "0", "4", "6", "8"},
+	}
+	var got [][]string
+	for _, row := range tab[2:] {
+		got = append(got, append([]string{row[0]}, row[3:11]...))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("treeFormat =\n%v\nwant\n%v", got, want)
+	}
+}
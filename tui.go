@@ -0,0 +1,785 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ui "github.com/gizak/termui"
+	"github.com/gizak/termui/widgets"
+)
+
+// Views the TUI can show
+const (
+	viewProcs   = "procs"
+	viewMaps    = "maps"
+	viewLibs    = "libs"
+	viewThreads = "threads"
+	viewTree    = "tree"
+	viewGroups  = "groups"
+	viewReport  = "report"
+	viewLeaks   = "leaks"
+	viewDetail  = "detail"
+	// Processes mapping one library
+	viewLibUsers = "libusers"
+)
+
+// Number of header rows at the top of every table
+const headerRows = 2
+
+// Height of the system memory summary above the table: a row of gauges
+// with borders and a line of figures
+const summaryHeight = 4
+
+// Height of the history sparklines below the table: three sparklines of a
+// title and two rows each, within a border
+const historyHeight = 11
+
+// Style of the selected row
+var selectedStyle = ui.NewStyle(ui.ColorBlack, ui.ColorWhite)
+
+// Style of zombie processes
+var zombieStyle = ui.NewStyle(ui.ColorRed, ui.ColorClear, ui.ModifierBold)
+
+// Style of the rows heading sections of the detail view
+var sectionStyle = ui.NewStyle(ui.ColorWhite, ui.ColorClear, ui.ModifierBold)
+
+// Style of processes the leak detector flags
+var leakStyle = ui.NewStyle(ui.ColorYellow, ui.ColorClear, ui.ModifierBold)
+
+// screen holds the widgets and the state of the interactive view
+type screen struct {
+	memGauge  *widgets.Gauge
+	swapGauge *widgets.Gauge
+	summary   *widgets.Paragraph
+	table     *widgets.Table
+	status    *widgets.Paragraph
+	history   *widgets.SparklineGroup
+	width     int
+	height    int
+	// Summed PSS and number of the processes last listed, and how many of
+	// them had no smaps to read
+	totalPSS     int
+	procCount    int
+	partialCount int
+	// How long the last refresh took to collect its data
+	elapsed time.Duration
+
+	view string
+	// Column titles, row contents and the identity of each row. Keys let
+	// the selection follow a row when the sort order changes.
+	header [][]string
+	rows   [][]string
+	keys   []string
+	// Styles of individual rows, by index into rows
+	styles map[int]ui.Style
+	// Index of the selected row and of the first row shown
+	selected int
+	offset   int
+	// Process whose mappings, threads or details the maps, threads and
+	// detail views show, and the view the first two were opened from
+	pid  int
+	from string
+	// Library whose processes the libusers view shows
+	libPath string
+	// Mapped files last read for the libs and libusers views, and when.
+	// Reading them parses the full smaps of every process, so it's only
+	// redone every libsInterval rather than on every refresh.
+	libs     []*Library
+	libsRead time.Time
+	// PIDs whose children are hidden in the tree view
+	collapsed map[int]bool
+	// Groups whose processes are listed in the groups view
+	expanded map[string]bool
+	// Whether the groups view sorts by process count rather than sortKey,
+	// which no other view can sort by
+	byCount bool
+	// Recent memory of the processes listed, by PID, for views that list
+	// processes, and whether to show it for the selected one
+	histories   map[string][]footprint
+	showHistory bool
+}
+
+func newScreen() *screen {
+	s := &screen{view: viewProcs, collapsed: make(map[int]bool), expanded: make(map[string]bool),
+		showHistory: true}
+	if groupBy != "" {
+		s.view = viewGroups
+	}
+	s.memGauge = widgets.NewGauge()
+	s.memGauge.Title = "Memory"
+	s.memGauge.BarColor = ui.ColorGreen
+	s.swapGauge = widgets.NewGauge()
+	s.swapGauge.Title = "Swap"
+	s.swapGauge.BarColor = ui.ColorYellow
+	s.summary = widgets.NewParagraph()
+	s.summary.Border = false
+	s.summary.WrapText = false
+	s.table = widgets.NewTable()
+	s.table.RowSeparator = false
+	s.table.BorderStyle = ui.NewStyle(ui.ColorBlack)
+	s.table.Border = false
+	s.status = widgets.NewParagraph()
+	s.status.Border = false
+	s.status.WrapText = false
+	s.history = widgets.NewSparklineGroup(newSparkline(ui.ColorCyan), newSparkline(ui.ColorGreen),
+		newSparkline(ui.ColorYellow))
+	s.history.BorderStyle = ui.NewStyle(ui.ColorBlack)
+	s.resize(ui.TerminalDimensions())
+	return s
+}
+
+// newSparkline returns a sparkline for the history of one figure
+func newSparkline(color ui.Color) *widgets.Sparkline {
+	sl := widgets.NewSparkline()
+	sl.LineColor = color
+	sl.TitleStyle = ui.NewStyle(ui.ColorWhite)
+	return sl
+}
+
+// resize lays out the widgets for a terminal of the given size
+func (s *screen) resize(width, height int) {
+	s.width, s.height = width, height
+	s.memGauge.SetRect(0, 0, width/2, summaryHeight-1)
+	s.swapGauge.SetRect(width/2, 0, width, summaryHeight-1)
+	s.summary.SetRect(0, summaryHeight-1, width, summaryHeight)
+	s.layoutTable()
+	s.status.SetRect(0, height-1, width, height)
+}
+
+// layoutTable gives the table the rest of the screen, less room for the
+// history of the selected process when it's shown
+func (s *screen) layoutTable() {
+	bottom := s.height - 1
+	if s.historyShown() {
+		bottom -= historyHeight
+		s.history.SetRect(0, bottom, s.width, s.height-1)
+	}
+	s.table.SetRect(0, summaryHeight, s.width, bottom)
+}
+
+// historyShown reports whether the history of the selected process fits
+// and is wanted
+func (s *screen) historyShown() bool {
+	return s.showHistory && s.histories != nil && s.height-1-historyHeight-summaryHeight > headerRows+3
+}
+
+// updateHistory fills the sparklines with the history of the selected
+// process. Each is scaled from the lowest to the highest figure, so that
+// slow growth on top of a large footprint still shows.
+func (s *screen) updateHistory() {
+	key, _ := s.selectedKey()
+	history := s.histories[key]
+	// Only as many samples as there are columns to draw them in
+	if n := s.history.Inner.Dx(); len(history) > n && n > 0 {
+		history = history[len(history)-n:]
+	}
+	figures := []struct {
+		name  string
+		value func(f footprint) int
+	}{
+		{"PSS", func(f footprint) int { return f.PSS }},
+		{"USS", func(f footprint) int { return f.USS }},
+		{"SwapPSS", func(f footprint) int { return f.Swap }},
+	}
+	for i, fig := range figures {
+		sl := s.history.Sparklines[i]
+		sl.Data = sl.Data[:0]
+		if len(history) == 0 {
+			sl.Title = fig.name
+			continue
+		}
+		min, max := fig.value(history[0]), fig.value(history[0])
+		for _, f := range history {
+			if v := fig.value(f); v < min {
+				min = v
+			} else if v > max {
+				max = v
+			}
+		}
+		for _, f := range history {
+			sl.Data = append(sl.Data, float64(fig.value(f)-min))
+		}
+		sl.MaxVal = float64(max - min)
+		if max == min {
+			sl.MaxVal = 1
+		}
+		last := fig.value(history[len(history)-1])
+		sl.Title = fmt.Sprintf("%s %s, %s to %s over the last %d seconds",
+			fig.name, humanKB(last), humanKB(min), humanKB(max), len(history))
+	}
+	s.history.Title = "PID " + key
+}
+
+// refresh recollects the data for the current view and redraws it
+func (s *screen) refresh() {
+	s.update(false)
+}
+
+// tick refreshes the current view and samples the growth of every process,
+// which only happens once a tick, even in views that don't list processes
+func (s *screen) tick() {
+	s.update(true)
+}
+
+// update recollects the data for the current view, sampling the growth of
+// every process first if asked, and redraws it
+func (s *screen) update(sample bool) {
+	start := time.Now()
+	var tab [][]string
+	var keys []string
+	var widths []int
+	styles := make(map[int]ui.Style)
+	status := ""
+	var procs []*Process
+	s.histories = nil
+	if (s.view != viewMaps && s.view != viewThreads) || sample {
+		procs = GetProcesses(procRoot)
+		if sample {
+			trackGrowth(procs)
+			sortProcesses(procs)
+		}
+		s.totalPSS, s.procCount, s.partialCount = 0, len(procs), 0
+		for _, p := range procs {
+			s.totalPSS += p.PSS
+			if p.Partial {
+				s.partialCount++
+			}
+		}
+	}
+	switch s.view {
+	case viewProcs, viewTree, viewLeaks:
+		s.histories = make(map[string][]footprint)
+		for _, p := range procs {
+			s.histories[strconv.Itoa(p.PID)] = historyOf(p)
+		}
+	}
+	switch s.view {
+	case viewProcs:
+		tab = tableFormat(procs)
+		for i, p := range procs {
+			keys = append(keys, strconv.Itoa(p.PID))
+			if p.IsZombie() {
+				styles[i] = zombieStyle
+			}
+		}
+		widths = []int{6, 18, 10, 1, 8, 8, 8, 8}
+		for range extraColumns {
+			widths = append(widths, 9)
+		}
+		widths = append(widths, 12, 22)
+		status = fmt.Sprintf("%d processes sorted by %s | enter: details  a: all  o: next sort  f/b: growth  m: mappings  H: threads  t: tree  g: group  l: libraries  w: where  q: quit",
+			len(procs), sortKey)
+	case viewMaps:
+		maps, err := readMappings(filepath.Join(procRoot, strconv.Itoa(s.pid)))
+		sortMappings(maps, sortKey)
+		tab = mapsFormat(maps)
+		for _, m := range maps {
+			keys = append(keys, fmt.Sprintf("%x", m.Start))
+		}
+		widths = []int{25, 4, 8, 9, 8, 8, 8, 8}
+		status = fmt.Sprintf("PID %d: %d mappings sorted by %s | esc: back  q: quit",
+			s.pid, len(maps), sortKey)
+		if err != nil {
+			status = fmt.Sprintf("PID %d: %v | esc: back  q: quit", s.pid, err)
+		}
+	case viewTree:
+		rows := flattenTree(buildTree(procs), s.collapsed)
+		tab = treeFormat(rows, s.collapsed)
+		for i, r := range rows {
+			keys = append(keys, strconv.Itoa(r.PID))
+			if r.IsZombie() {
+				styles[i] = zombieStyle
+			}
+		}
+		widths = []int{6, 16, 10, 8, 8, 8, 8, 8, 8, 8, 8}
+		status = fmt.Sprintf("%d processes, subtrees sorted by %s | enter: collapse/expand  esc: back  q: quit",
+			len(procs), sortKey)
+	case viewGroups:
+		g, _ := findGrouping(groupBy)
+		groups := GroupProcesses(procs, g.Key)
+		widths = []int{6, 8, 8, 8, 8}
+		if groupBy == "cgroup" {
+			addCgroupMemory(groups)
+			for range cgroupTitles {
+				widths = append(widths, 9)
+			}
+		}
+		key := sortKey
+		if s.byCount {
+			key = "count"
+			sortGroups(groups, key)
+		}
+		tab, keys = groupsFormat(groups, g.Title, s.expanded)
+		status = fmt.Sprintf("%d groups by %s sorted by %s | g: next grouping  c: sort by count  enter: expand  q: quit",
+			len(groups), g.Name, key)
+	case viewThreads:
+		threads, err := GetThreads(filepath.Join(procRoot, strconv.Itoa(s.pid)))
+		tab = threadsFormat(threads)
+		for _, t := range threads {
+			keys = append(keys, strconv.Itoa(t.TID))
+		}
+		widths = []int{7, 16, 1, 8, 8}
+		status = fmt.Sprintf("PID %d: %d threads, %d kB of stacks resident | esc: back  q: quit",
+			s.pid, len(threads), stacksRSS(threads))
+		if err != nil {
+			status = fmt.Sprintf("PID %d: %v | esc: back  q: quit", s.pid, err)
+		}
+	case viewReport:
+		info, err := readMeminfo(procRoot)
+		lines := buildReport(info, procs, readSocketMemory(procRoot))
+		tab = reportFormat(lines, info["MemTotal"])
+		for _, l := range lines {
+			keys = append(keys, l.Label)
+		}
+		widths = []int{12, 7, 5, 26}
+		status = "Where MemTotal went | esc: back  q: quit"
+		if err != nil {
+			status = fmt.Sprintf("meminfo: %v | esc: back  q: quit", err)
+		}
+	case viewDetail:
+		lines, err := readDetail(filepath.Join(procRoot, strconv.Itoa(s.pid)))
+		labelWidth := 16
+		var sections []int
+		tab, keys, sections = detailFormat(lines, s.width-labelWidth-1)
+		for _, i := range sections {
+			styles[i] = sectionStyle
+		}
+		widths = []int{labelWidth}
+		status = fmt.Sprintf("PID %d | m: mappings  H: threads  esc: back  q: quit", s.pid)
+		if err != nil {
+			status = fmt.Sprintf("PID %d: %v | esc: back  q: quit", s.pid, err)
+		}
+	case viewLeaks:
+		sortLeaks(procs)
+		tab = leaksFormat(procs)
+		leaking := 0
+		for i, p := range procs {
+			keys = append(keys, strconv.Itoa(p.PID))
+			if p.Trend.Leaking {
+				leaking++
+				styles[i] = leakStyle
+			}
+		}
+		widths = []int{6, 16, 10, 8, 9, 7, 8, 4}
+		status = fmt.Sprintf("%d of %d processes grew steadily by more than %d kB over %s | esc: back  q: quit",
+			leaking, len(procs), leakNoise, leakWindow)
+	case viewLibs:
+		libs := s.libraries(procs)
+		tab = libsFormat(libs)
+		for _, lib := range libs {
+			keys = append(keys, lib.Path)
+		}
+		widths = []int{6, 8, 8, 8, 8}
+		status = fmt.Sprintf("%d mapped files sorted by %s, read %s ago | enter: processes  esc: back  q: quit",
+			len(libs), sortKey, time.Since(s.libsRead).Round(time.Second))
+	case viewLibUsers:
+		lib := findLibrary(s.libraries(procs), s.libPath)
+		if lib == nil {
+			lib = &Library{Path: s.libPath}
+		}
+		tab = libUsersFormat(lib)
+		for _, u := range lib.Users {
+			keys = append(keys, strconv.Itoa(u.PID))
+		}
+		widths = []int{6, 18, 10, 8, 8, 8, 8}
+		status = fmt.Sprintf("%s: mapped by %d processes, %d kB PSS | esc: back  q: quit",
+			lib.Path, len(lib.Users), lib.PSS)
+	}
+	s.elapsed = time.Since(start)
+	s.setRows(tab, keys)
+	s.styles = styles
+	s.table.ColumnWidths = fillWidths(widths, s.width)
+	s.status.Text = status
+	s.updateSummary()
+	s.draw()
+}
+
+// How often the libs and libusers views reread the mappings of every process
+const libsInterval = 10 * time.Second
+
+// libraries returns the mapped files of the processes, reading them again
+// only when they're older than libsInterval
+func (s *screen) libraries(procs []*Process) []*Library {
+	if s.libs == nil || time.Since(s.libsRead) >= libsInterval {
+		s.libs = GetLibraries(procs)
+		s.libsRead = time.Now()
+		return s.libs
+	}
+	sortLibraries(s.libs, sortKey)
+	return s.libs
+}
+
+// updateSummary fills the gauges and figures from /proc/meminfo
+func (s *screen) updateSummary() {
+	info, err := readMeminfo(procRoot)
+	if err != nil {
+		s.summary.Text = fmt.Sprintf("meminfo: %v", err)
+		return
+	}
+	total, avail := info["MemTotal"], info["MemAvailable"]
+	s.memGauge.Percent = percentOf(total-avail, total)
+	s.memGauge.Label = fmt.Sprintf("%d%% of %s used, %s available",
+		s.memGauge.Percent, humanKB(total), humanKB(avail))
+	swapTotal, swapFree := info["SwapTotal"], info["SwapFree"]
+	s.swapGauge.Percent = percentOf(swapTotal-swapFree, swapTotal)
+	s.swapGauge.Label = fmt.Sprintf("%d%% of %s used, %s free",
+		s.swapGauge.Percent, humanKB(swapTotal), humanKB(swapFree))
+	if swapTotal == 0 {
+		s.swapGauge.Label = "no swap"
+	}
+	s.summary.Text = fmt.Sprintf("Cached %s  Buffers %s  Shmem %s  Slab %s  |  PSS of %d processes: %s (%d%% of total)  |  refreshed in %s",
+		humanKB(info["Cached"]), humanKB(info["Buffers"]), humanKB(info["Shmem"]), humanKB(info["Slab"]),
+		s.procCount, humanKB(s.totalPSS), percentOf(s.totalPSS, total), s.elapsed.Round(time.Millisecond))
+	if s.partialCount > 0 {
+		s.summary.Text += fmt.Sprintf("  |  %d without smaps, left out of totals marked *; run as root for their PSS",
+			s.partialCount)
+	}
+	if replay != nil {
+		paused := ""
+		if replay.Paused {
+			paused = " (paused)"
+		}
+		s.summary.Text = fmt.Sprintf("REPLAY %d/%d %s%s  |  %s", replay.Index()+1, replay.Len(),
+			replay.Time().Format("2006-01-02 15:04:05"), paused, s.summary.Text)
+	}
+}
+
+// setRows replaces the table contents, keeping the selection on the same
+// row key if it's still present
+func (s *screen) setRows(tab [][]string, keys []string) {
+	var selectedKey string
+	if s.selected < len(s.keys) {
+		selectedKey = s.keys[s.selected]
+	}
+	s.header = tab[:headerRows]
+	s.rows = tab[headerRows:]
+	s.keys = keys
+	for i, k := range keys {
+		if k == selectedKey {
+			s.selected = i
+			return
+		}
+	}
+	s.move(0)
+}
+
+// move shifts the selection by delta rows, staying within the table
+func (s *screen) move(delta int) {
+	s.selected += delta
+	if s.selected >= len(s.rows) {
+		s.selected = len(s.rows) - 1
+	}
+	if s.selected < 0 {
+		s.selected = 0
+	}
+}
+
+// pageSize is the number of rows that fit below the table header
+func (s *screen) pageSize() int {
+	n := s.table.Inner.Dy() - headerRows
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// draw renders the window of rows around the selection
+func (s *screen) draw() {
+	s.layoutTable()
+	page := s.pageSize()
+	if s.selected < s.offset {
+		s.offset = s.selected
+	}
+	if s.selected >= s.offset+page {
+		s.offset = s.selected - page + 1
+	}
+	end := s.offset + page
+	if end > len(s.rows) {
+		end = len(s.rows)
+	}
+	s.table.Rows = append([][]string{}, s.header...)
+	for _, row := range s.rows[s.offset:end] {
+		s.table.Rows = append(s.table.Rows, escapeRow(row))
+	}
+	s.table.RowStyles = make(map[int]ui.Style)
+	for i, style := range s.styles {
+		if i >= s.offset && i < end {
+			s.table.RowStyles[headerRows+i-s.offset] = style
+		}
+	}
+	if len(s.rows) > 0 {
+		s.table.RowStyles[headerRows+s.selected-s.offset] = selectedStyle
+	}
+	ui.Clear()
+	ui.Render(s.memGauge, s.swapGauge, s.summary, s.table, s.status)
+	if s.historyShown() {
+		s.updateHistory()
+		ui.Render(s.history)
+	}
+}
+
+// selectedPID returns the PID of the selected process in the procs view
+func (s *screen) selectedPID() (int, bool) {
+	if s.view != viewProcs || s.selected >= len(s.keys) {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(s.keys[s.selected])
+	return pid, err == nil
+}
+
+// pickProcess chooses the process for the maps or threads view: the one
+// selected in the procs view or the one the detail view shows
+func (s *screen) pickProcess() bool {
+	if s.view == viewDetail {
+		s.from = viewDetail
+		return true
+	}
+	pid, ok := s.selectedPID()
+	if !ok {
+		return false
+	}
+	s.pid, s.from = pid, viewProcs
+	return true
+}
+
+// selectedKey returns the key of the selected row
+func (s *screen) selectedKey() (string, bool) {
+	if s.selected >= len(s.keys) {
+		return "", false
+	}
+	return s.keys[s.selected], true
+}
+
+// sortBy sorts every view by the given key and redraws the current one
+func (s *screen) sortBy(key string) {
+	sortKey = key
+	s.byCount = false
+	s.refresh()
+}
+
+// show switches to another view
+func (s *screen) show(view string) {
+	s.view = view
+	s.selected, s.offset = 0, 0
+	s.keys = nil
+	s.refresh()
+}
+
+// back returns to the view the current one was opened from
+func (s *screen) back() {
+	switch s.view {
+	case viewTree, viewReport, viewLibs, viewLeaks, viewDetail:
+		s.show(viewProcs)
+	case viewMaps, viewThreads:
+		s.show(s.from)
+	case viewGroups:
+		groupBy = ""
+		s.show(viewProcs)
+	case viewLibUsers:
+		s.show(viewLibs)
+	}
+}
+
+// escapeRow keeps termui's style parser from dropping the closing bracket
+// of cells like [heap] or [kthreadd]. Wrapping them as "[text]()" applies
+// the row's own style to the text.
+func escapeRow(row []string) []string {
+	escaped := make([]string, len(row))
+	for i, cell := range row {
+		if strings.HasSuffix(cell, "]") {
+			cell = "[" + cell + "]()"
+		}
+		escaped[i] = cell
+	}
+	return escaped
+}
+
+// fillWidths gives the last column whatever width the others leave over
+func fillWidths(widths []int, total int) []int {
+	rest := total
+	for _, w := range widths {
+		rest -= w + 1
+	}
+	if rest < 1 {
+		rest = 1
+	}
+	return append(widths, rest)
+}
+
+func runTermui(leaks bool) {
+	if err := ui.Init(); err != nil {
+		log.Fatalln("cannot initialize termui")
+	}
+	defer ui.Close()
+
+	s := newScreen()
+	if leaks {
+		s.view = viewLeaks
+	}
+	s.tick()
+
+	// Event Handlers
+
+	uiEvents := ui.PollEvents()
+	ticker := time.NewTicker(time.Second).C
+	for {
+		select {
+		case e := <-uiEvents:
+			switch e.ID {
+			case "q", "<C-c>":
+				return
+			case "r":
+				s.sortBy("rss")
+			case "u":
+				s.sortBy("uss")
+			case "p":
+				s.sortBy("pss")
+			case "n":
+				s.sortBy("name")
+			case "s":
+				s.sortBy("swap")
+			case "f":
+				s.sortBy(sortGrowing)
+			case "b":
+				s.sortBy(sortGrowth)
+			case "a":
+				showAll = !showAll
+				s.refresh()
+			case "o":
+				s.sortBy(nextSortKey(sortKey))
+			case "m":
+				if s.pickProcess() {
+					s.show(viewMaps)
+				}
+			case "<Escape>", "<Backspace>", "<C-<Backspace>>":
+				s.back()
+			case "H":
+				if s.pickProcess() {
+					s.show(viewThreads)
+				}
+			case "l":
+				if s.view == viewProcs {
+					s.libs = nil
+					s.show(viewLibs)
+				}
+			case "c":
+				if s.view == viewGroups {
+					s.byCount = true
+					s.refresh()
+				}
+			case "g":
+				if s.view != viewProcs && s.view != viewGroups {
+					break
+				}
+				groupBy = nextGrouping(groupBy)
+				s.expanded = make(map[string]bool)
+				if groupBy == "" {
+					s.show(viewProcs)
+				} else {
+					s.show(viewGroups)
+				}
+			case "w":
+				if s.view == viewProcs {
+					s.show(viewReport)
+				}
+			case "t":
+				if s.view == viewProcs {
+					s.show(viewTree)
+				}
+			case "v":
+				s.showHistory = !s.showHistory
+				s.draw()
+			case "L":
+				if s.view == viewProcs {
+					s.show(viewLeaks)
+				}
+			case "<Enter>", "<Space>":
+				key, ok := s.selectedKey()
+				if !ok {
+					break
+				}
+				switch s.view {
+				case viewProcs:
+					s.pid, _ = strconv.Atoi(key)
+					s.show(viewDetail)
+				case viewLibs:
+					s.libPath = key
+					s.show(viewLibUsers)
+				case viewTree:
+					pid, _ := strconv.Atoi(key)
+					s.collapsed[pid] = !s.collapsed[pid]
+					s.refresh()
+				case viewGroups:
+					if strings.HasPrefix(key, "g:") {
+						group := strings.TrimPrefix(key, "g:")
+						s.expanded[group] = !s.expanded[group]
+						s.refresh()
+					}
+				}
+			case "<Up>":
+				s.move(-1)
+				s.draw()
+			case "<Down>":
+				s.move(1)
+				s.draw()
+			case "<PageUp>":
+				s.move(-s.pageSize())
+				s.draw()
+			case "<PageDown>":
+				s.move(s.pageSize())
+				s.draw()
+			case "<Home>":
+				s.move(-len(s.rows))
+				s.draw()
+			case "<End>":
+				s.move(len(s.rows))
+				s.draw()
+			case "P", ",", ".", "<", ">":
+				if replay == nil {
+					break
+				}
+				if e.ID == "P" {
+					replay.Paused = !replay.Paused
+					s.refresh()
+					break
+				}
+				frame := replay.Index()
+				switch e.ID {
+				case ",":
+					replay.Paused = true
+					replay.Seek(-1)
+				case ".":
+					replay.Paused = true
+					replay.Seek(1)
+				case "<":
+					replay.Seek(-10)
+				case ">":
+					replay.Seek(10)
+				}
+				// Each frame reached is sampled, as if ticked to
+				if replay.Index() != frame {
+					s.tick()
+				} else {
+					s.refresh()
+				}
+			case "<Resize>":
+				payload := e.Payload.(ui.Resize)
+				s.resize(payload.Width, payload.Height)
+				s.refresh()
+			}
+
+		case <-ticker:
+			if replay != nil {
+				if replay.Paused {
+					break
+				}
+				replay.Tick()
+			}
+			s.tick()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Name the users of processes in other mount namespaces from their own
+// /etc/passwd. Reading it through /proc/<pid>/root needs root.
+var containerUsers = false
+
+// uidRange is one line of a uid_map: Count UIDs starting at Inside in the
+// process's user namespace are Outside and on in ours
+type uidRange struct {
+	Inside, Outside, Count uint32
+}
+
+// passwd files of the mount namespaces seen so far, by namespace. Files
+// that couldn't be read are kept as nil so they aren't retried.
+var (
+	passwdCache = make(map[string]map[uint32]string)
+	passwdMu    sync.Mutex
+)
+
+// readUIDMap reads the uid_map of the process at path
+func readUIDMap(path string) ([]uidRange, error) {
+	contents, err := readFile(filepath.Join(path, "uid_map"))
+	if err != nil {
+		return nil, err
+	}
+	var ranges []uidRange
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		var ids [3]uint32
+		for i, field := range fields {
+			id, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("malformed uid_map line %q", line)
+			}
+			ids[i] = uint32(id)
+		}
+		ranges = append(ranges, uidRange{Inside: ids[0], Outside: ids[1], Count: ids[2]})
+	}
+	return ranges, nil
+}
+
+// isIdentityMap reports whether a uid_map maps every UID to itself, as it
+// does for processes sharing our user namespace
+func isIdentityMap(ranges []uidRange) bool {
+	for _, r := range ranges {
+		if r.Inside != r.Outside {
+			return false
+		}
+	}
+	return true
+}
+
+// insideUID maps a UID of ours to the UID it is in the process's user
+// namespace
+func insideUID(ranges []uidRange, uid uint32) (uint32, bool) {
+	for _, r := range ranges {
+		if uid >= r.Outside && uid-r.Outside < r.Count {
+			return uid - r.Outside + r.Inside, true
+		}
+	}
+	return 0, false
+}
+
+// localUsername returns who uid is inside the namespaces of the process at
+// path, when that differs from who it is on the host. That's the name from
+// the process's own /etc/passwd with -container-users, or else the UID
+// inside its user namespace.
+func localUsername(path string, uid uint32) (string, bool) {
+	inside := uid
+	ranges, err := readUIDMap(path)
+	mapped := err == nil && !isIdentityMap(ranges)
+	if mapped {
+		var ok bool
+		if inside, ok = insideUID(ranges, uid); !ok {
+			return "", false
+		}
+	}
+	if containerUsers {
+		if name, ok := containerPasswd(path)[inside]; ok && (mapped || name != lookupUsername(uid)) {
+			return name, true
+		}
+	}
+	if mapped {
+		return strconv.FormatUint(uint64(inside), 10), true
+	}
+	return "", false
+}
+
+// Largest /etc/passwd read from a container
+const maxPasswdSize = 1 << 20
+
+// containerPasswd returns the users in /etc/passwd of the process at path,
+// or nil if it shares the mount namespace of the host's init. The file is
+// the container's to make anything it likes, so a symlinked /etc or passwd
+// is refused, and the read is bounded and done without holding passwdMu.
+func containerPasswd(path string) map[uint32]string {
+	ns, err := fsys.Readlink(filepath.Join(path, "ns", "mnt"))
+	if err != nil {
+		return nil
+	}
+	host, _ := fsys.Readlink(filepath.Join(procRoot, "1", "ns", "mnt"))
+	if ns == host {
+		return nil
+	}
+	passwdMu.Lock()
+	users, ok := passwdCache[ns]
+	passwdMu.Unlock()
+	if ok {
+		return users
+	}
+	passwd := filepath.Join(path, "root", "etc", "passwd")
+	if contents, err := fsys.ReadRegular(passwd, maxPasswdSize); err == nil {
+		users = parsePasswd(contents)
+	}
+	passwdMu.Lock()
+	passwdCache[ns] = users
+	passwdMu.Unlock()
+	return users
+}
+
+// Users in the passwd file of the host procRoot belongs to, read once
+var (
+	hostPasswd     map[uint32]string
+	hostPasswdOnce sync.Once
+)
+
+// hostUsers returns the users in /etc/passwd of procRoot's init, which is
+// the host's own when procRoot is its procfs mounted elsewhere, or nil if it
+// can't be read
+func hostUsers() map[uint32]string {
+	hostPasswdOnce.Do(func() {
+		passwd := filepath.Join(procRoot, "1", "root", "etc", "passwd")
+		if contents, err := fsys.ReadRegular(passwd, maxPasswdSize); err == nil {
+			hostPasswd = parsePasswd(contents)
+		}
+	})
+	return hostPasswd
+}
+
+// parsePasswd reads the names and UIDs of a passwd file
+func parsePasswd(contents []byte) map[uint32]string {
+	users := make(map[uint32]string)
+	for _, line := range bytes.Split(contents, []byte("\n")) {
+		// name:password:UID:GID:GECOS:directory:shell
+		fields := strings.Split(string(line), ":")
+		if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		uid, err := strconv.ParseUint(fields[2], 10, 32)
+		if err != nil {
+			continue
+		}
+		if _, ok := users[uint32(uid)]; !ok {
+			users[uint32(uid)] = fields[0]
+		}
+	}
+	return users
+}
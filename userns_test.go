@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func TestReadUIDMap(t *testing.T) {
+	tests := []struct {
+		path     string
+		want     []uidRange
+		identity bool
+	}{
+		// Our own namespace, and a container with 65536 UIDs from 100000
+		{"testdata/proc/27933", []uidRange{{0, 0, 4294967295}}, true},
+		{"testdata/proc/27990", []uidRange{{0, 100000, 65536}}, false},
+	}
+	for _, tt := range tests {
+		ranges, err := readUIDMap(tt.path)
+		if err != nil {
+			t.Errorf("readUIDMap(%q): %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(ranges, tt.want) {
+			t.Errorf("readUIDMap(%q) = %v, want %v", tt.path, ranges, tt.want)
+		}
+		if got := isIdentityMap(ranges); got != tt.identity {
+			t.Errorf("isIdentityMap(%v) = %v, want %v", ranges, got, tt.identity)
+		}
+	}
+}
+
+func TestInsideUID(t *testing.T) {
+	// A rootless podman container: the user is root inside, and their
+	// subordinate UIDs from 100000 are 1 and up
+	rootless := []uidRange{{0, 1000, 1}, {1, 100000, 65536}}
+	tests := []struct {
+		ranges []uidRange
+		uid    uint32
+		want   uint32
+		ok     bool
+	}{
+		{rootless, 1000, 0, true},
+		{rootless, 100000, 1, true},
+		{rootless, 100100, 101, true},
+		{rootless, 165535, 65536, true},
+		{rootless, 165536, 0, false},
+		{rootless, 0, 0, false},
+		{rootless, 999, 0, false},
+		{[]uidRange{{0, 0, 4294967295}}, 4294967294, 4294967294, true},
+		{nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := insideUID(tt.ranges, tt.uid)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("insideUID(%v, %d) = %d, %v; want %d, %v", tt.ranges, tt.uid, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParsePasswd(t *testing.T) {
+	passwd := `root:x:0:0:root:/root:/bin/bash
+daemon:x:1:1:daemon:/usr/sbin:/usr/sbin/nologin
+# a comment:x:5:5
+nginx:x:101:101:nginx user:/nonexistent:/bin/false
+toor:x:0:0:second root:/root:/bin/sh
+broken:x:notanumber:0::/:
+short:x
+
+nobody:x:65534:65534:nobody:/nonexistent:/usr/sbin/nologin`
+	want := map[uint32]string{0: "root", 1: "daemon", 101: "nginx", 65534: "nobody"}
+	if got := parsePasswd([]byte(passwd)); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePasswd = %v, want %v", got, want)
+	}
+}
+
+func TestContainerPasswd(t *testing.T) {
+	root := t.TempDir()
+	defer func(saved string) { procRoot = saved }(procRoot)
+	procRoot = root
+	mkdirs(t, root+"/1/ns")
+	symlink(t, "mnt:[4026531841]", root+"/1/ns/mnt")
+	passwd := []byte("root:x:0:0:root:/root:/bin/sh\nnginx:x:101:101::/nonexistent:/bin/false\n")
+
+	// A process sharing init's mount namespace, and containers whose
+	// /etc/passwd is a file, a FIFO, a symlink, in a symlinked /etc, or
+	// too large
+	procs := map[string]func(dir string){
+		"host": func(dir string) {},
+		"file": func(dir string) {
+			writeFile(t, dir+"/root/etc/passwd", passwd)
+		},
+		"fifo": func(dir string) {
+			if err := syscall.Mkfifo(dir+"/root/etc/passwd", 0644); err != nil {
+				t.Fatal(err)
+			}
+		},
+		"symlink": func(dir string) {
+			symlink(t, "/dev/zero", dir+"/root/etc/passwd")
+		},
+		"symlinked etc": func(dir string) {
+			writeFile(t, dir+"/elsewhere/passwd", passwd)
+			if err := os.Remove(dir + "/root/etc"); err != nil {
+				t.Fatal(err)
+			}
+			symlink(t, dir+"/elsewhere", dir+"/root/etc")
+		},
+		"large": func(dir string) {
+			writeFile(t, dir+"/root/etc/passwd", bytes.Repeat(passwd, maxPasswdSize/len(passwd)+1))
+		},
+	}
+	want := map[string]map[uint32]string{
+		"file": {0: "root", 101: "nginx"},
+	}
+	n := 0
+	for name, setup := range procs {
+		n++
+		dir := fmt.Sprintf("%s/%d", root, 100+n)
+		mkdirs(t, dir+"/ns", dir+"/root/etc", dir+"/elsewhere")
+		ns := fmt.Sprintf("mnt:[%d]", 4026532000+n)
+		if name == "host" {
+			ns = "mnt:[4026531841]"
+		}
+		symlink(t, ns, dir+"/ns/mnt")
+		setup(dir)
+		if got := containerPasswd(dir); !reflect.DeepEqual(got, want[name]) {
+			t.Errorf("%s: containerPasswd = %v, want %v", name, got, want[name])
+		}
+	}
+}
+
+func TestLookupUsernameHost(t *testing.T) {
+	root := t.TempDir()
+	defer func(saved string) { procRoot = saved }(procRoot)
+	procRoot = root
+	defer func() { ucache = map[uint32]string{}; hostPasswdOnce = sync.Once{} }()
+	ucache = map[uint32]string{}
+	hostPasswdOnce = sync.Once{}
+	mkdirs(t, root+"/1/root/etc")
+	writeFile(t, root+"/1/root/etc/passwd", []byte("root:x:0:0:root:/root:/bin/sh\nnginx:x:101:101::/nonexistent:/bin/false\n"))
+
+	// UIDs come from the host's passwd, not ours, and missing ones show as
+	// the number
+	for uid, want := range map[uint32]string{0: "root", 101: "nginx", 4242: "4242"} {
+		if got := lookupUsername(uid); got != want {
+			t.Errorf("lookupUsername(%d) = %q, want %q", uid, got, want)
+		}
+	}
+}
+
+func mkdirs(t *testing.T, dirs ...string) {
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func symlink(t *testing.T, target, name string) {
+	if err := os.Symlink(target, name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, name string, contents []byte) {
+	if err := ioutil.WriteFile(name, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+}